@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind categorizes AI failures so callers can react (and explain) differently.
+type ErrorKind string
+
+const (
+	KindTimeout         ErrorKind = "timeout"          // deadline exceeded or provider too slow
+	KindRateLimited     ErrorKind = "rate_limited"     // temporary 429; retrying later helps
+	KindQuota           ErrorKind = "quota_exceeded"   // account quota or billing limit reached
+	KindAuth            ErrorKind = "auth"             // missing or rejected API key
+	KindBadInput        ErrorKind = "bad_input"        // provider rejected the request (e.g. bad image)
+	KindUnavailable     ErrorKind = "unavailable"      // network failure or provider 5xx
+	KindInvalidResponse ErrorKind = "invalid_response" // response could not be understood
+	KindBudget          ErrorKind = "budget_exceeded"  // local spending cap reached; request not sent
+)
+
+// Error is a categorized AI failure.
+type Error struct {
+	Kind       ErrorKind
+	Status     int           // HTTP status from the provider, if any
+	Message    string        // provider error message or description
+	RetryAfter time.Duration // provider-requested wait before retrying
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("AI %s (%d): %s", e.Kind, e.Status, e.Message)
+	}
+	return fmt.Sprintf("AI %s: %s", e.Kind, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the category of err, or "" if it is not an AI error.
+func KindOf(err error) ErrorKind {
+	var aiErr *Error
+	if errors.As(err, &aiErr) {
+		return aiErr.Kind
+	}
+	return ""
+}
+
+// Retryable reports whether repeating the request may succeed.
+func (k ErrorKind) Retryable() bool {
+	return k == KindRateLimited || k == KindUnavailable || k == KindTimeout
+}
+
+// transportError categorizes a failure to get any HTTP response.
+func transportError(ctx context.Context, err error) *Error {
+	if ctx.Err() != nil {
+		return &Error{Kind: KindTimeout, Message: "request deadline exceeded", Err: ctx.Err()}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &Error{Kind: KindTimeout, Message: "provider did not respond in time", Err: err}
+	}
+	return &Error{Kind: KindUnavailable, Message: err.Error(), Err: err}
+}
+
+// statusError categorizes a non-200 provider response.
+func statusError(resp *http.Response, body []byte) *Error {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &parsed)
+
+	msg := parsed.Error.Message
+	if msg == "" {
+		msg = strings.TrimSpace(string(body))
+	}
+	e := &Error{Status: resp.StatusCode, Message: msg, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if parsed.Error.Code == "insufficient_quota" || parsed.Error.Type == "insufficient_quota" {
+			e.Kind = KindQuota
+		} else {
+			e.Kind = KindRateLimited
+		}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		e.Kind = KindAuth
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		e.Kind = KindTimeout
+	case resp.StatusCode >= 500:
+		e.Kind = KindUnavailable
+	default:
+		e.Kind = KindBadInput
+	}
+	return e
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
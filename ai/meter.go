@@ -0,0 +1,34 @@
+package ai
+
+import "context"
+
+// Meter accounts for AI usage. Allow is consulted before every request and
+// may refuse it, e.g. once a spending budget is used up; Record is called
+// with every successful response. Both get the request's context, for
+// logging.
+type Meter interface {
+	Allow(ctx context.Context, req ChatRequest) error
+	Record(ctx context.Context, req ChatRequest, resp *ChatResponse)
+}
+
+type metered struct {
+	Provider
+	meter Meter
+}
+
+// WithMeter reports each request to m and refuses those m does not allow.
+func WithMeter(p Provider, m Meter) Provider {
+	return &metered{Provider: p, meter: m}
+}
+
+func (m *metered) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := m.meter.Allow(ctx, req); err != nil {
+		return nil, err
+	}
+	resp, err := m.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	m.meter.Record(ctx, req, resp)
+	return resp, nil
+}
@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI is a Provider for the OpenAI chat completions API and compatible servers.
+type OpenAI struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewOpenAI creates a provider, filling in defaults for empty config fields.
+func NewOpenAI(cfg Config) *OpenAI {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.openai.com/v1"
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.Model == "" {
+		cfg.Model = "gpt-4o"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+	return &OpenAI{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Model returns the configured model name.
+func (o *OpenAI) Model() string {
+	return o.cfg.Model
+}
+
+// Chat sends a chat completion request and returns the first choice.
+func (o *OpenAI) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body := map[string]any{
+		"model":       o.cfg.Model,
+		"temperature": req.Temperature,
+		"messages":    req.Messages,
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+	if req.Schema != nil {
+		body["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   req.Schema.Name,
+				"strict": true,
+				"schema": req.Schema.Schema,
+			},
+		}
+	}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.cfg.BaseURL+"/chat/completions", bytes.NewReader(bodyJSON))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.cfg.APIKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, respBody)
+	}
+
+	var parsed struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, &Error{Kind: KindInvalidResponse, Message: "failed to parse AI response", Err: err}
+	}
+
+	if len(parsed.Choices) == 0 {
+		return nil, &Error{Kind: KindInvalidResponse, Message: "AI provider returned no choices"}
+	}
+
+	model := parsed.Model
+	if model == "" {
+		model = o.cfg.Model
+	}
+	return &ChatResponse{
+		Content: strings.TrimSpace(parsed.Choices[0].Message.Content),
+		Model:   model,
+		Usage:   parsed.Usage,
+	}, nil
+}
@@ -0,0 +1,125 @@
+// Package ai talks to chat-completion language models. The OpenAI provider
+// works with api.openai.com and any OpenAI-compatible server (e.g. a
+// self-hosted model or a local stub) by pointing BaseURL at it.
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Provider sends chat completion requests to a model.
+type Provider interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// Model returns the default model name used for requests.
+	Model() string
+}
+
+// Config configures an OpenAI-compatible provider.
+type Config struct {
+	BaseURL string        // e.g. "https://api.openai.com/v1"
+	Model   string        // e.g. "gpt-4o"
+	APIKey  string        // sent as a bearer token when non-empty
+	Timeout time.Duration // per HTTP request
+}
+
+// ChatRequest is a provider-neutral chat completion request.
+type ChatRequest struct {
+	Endpoint    string // caller name for usage accounting, e.g. "analyze"
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+	Schema      *Schema // request structured JSON output; nil for free text
+}
+
+// ChatResponse is the first choice of a completion plus token usage.
+type ChatResponse struct {
+	Content string
+	Model   string
+	Usage   Usage
+}
+
+// Usage reports tokens consumed by a request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Message is a single chat message made of text and image parts.
+type Message struct {
+	Role  string
+	Parts []Part
+}
+
+// Part is either text or an image URL (usually a data: URL).
+type Part struct {
+	Text     string
+	ImageURL string
+	Detail   string // image detail hint: "low", "high" or "auto"
+}
+
+// System returns a system message with plain text content.
+func System(text string) Message {
+	return Message{Role: "system", Parts: []Part{Text(text)}}
+}
+
+// User returns a user message with the given parts.
+func User(parts ...Part) Message {
+	return Message{Role: "user", Parts: parts}
+}
+
+// Assistant returns an assistant message with plain text content.
+func Assistant(text string) Message {
+	return Message{Role: "assistant", Parts: []Part{Text(text)}}
+}
+
+// Text returns a text part.
+func Text(s string) Part {
+	return Part{Text: s}
+}
+
+// Image returns an image part. Bare base64 is assumed to be JPEG.
+func Image(dataURL, detail string) Part {
+	if !strings.HasPrefix(dataURL, "data:") && !strings.HasPrefix(dataURL, "http") {
+		dataURL = "data:image/jpeg;base64," + dataURL
+	}
+	return Part{ImageURL: dataURL, Detail: detail}
+}
+
+// MarshalJSON encodes a message in the OpenAI chat format: plain string
+// content for text-only system/assistant messages, a parts array otherwise.
+func (m Message) MarshalJSON() ([]byte, error) {
+	if m.Role != "user" && len(m.Parts) == 1 && m.Parts[0].ImageURL == "" {
+		return json.Marshal(map[string]any{"role": m.Role, "content": m.Parts[0].Text})
+	}
+
+	content := make([]map[string]any, 0, len(m.Parts))
+	for _, p := range m.Parts {
+		if p.ImageURL != "" {
+			img := map[string]any{"url": p.ImageURL}
+			if p.Detail != "" {
+				img["detail"] = p.Detail
+			}
+			content = append(content, map[string]any{"type": "image_url", "image_url": img})
+		} else {
+			content = append(content, map[string]any{"type": "text", "text": p.Text})
+		}
+	}
+	return json.Marshal(map[string]any{"role": m.Role, "content": content})
+}
+
+// ExtractJSON strips markdown code fences that models sometimes wrap
+// around JSON output.
+func ExtractJSON(raw string) string {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "```") {
+		if idx := strings.Index(s, "\n"); idx >= 0 {
+			s = s[idx+1:]
+		}
+	}
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy configures WithRetry.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first
+	BaseDelay   time.Duration // first backoff delay, doubled per attempt
+	MaxDelay    time.Duration // cap for a single backoff delay
+	Deadline    time.Duration // overall budget for all attempts (0 = none)
+}
+
+type retrying struct {
+	Provider
+	policy RetryPolicy
+}
+
+// WithRetry retries rate-limited, unavailable and timed-out requests with
+// exponential backoff, honoring the provider's Retry-After. The whole call,
+// including waits, is bounded by policy.Deadline.
+func WithRetry(p Provider, policy RetryPolicy) Provider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = time.Second
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 30 * time.Second
+	}
+	return &retrying{Provider: p, policy: policy}
+}
+
+func (r *retrying) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if r.policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.policy.Deadline)
+		defer cancel()
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < r.policy.MaxAttempts; attempt++ {
+		resp, err := r.Provider.Chat(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		var aiErr *Error
+		if !errors.As(err, &aiErr) || !aiErr.Kind.Retryable() || ctx.Err() != nil {
+			break
+		}
+		if attempt == r.policy.MaxAttempts-1 {
+			break
+		}
+
+		delay := r.backoff(attempt)
+		if aiErr.RetryAfter > delay {
+			delay = aiErr.RetryAfter
+		}
+		// Don't sleep past the deadline only to fail anyway
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			break
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &Error{Kind: KindTimeout, Message: "request deadline exceeded", Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+	return nil, lastErr
+}
+
+// backoff returns the jittered delay before retry number attempt+1.
+func (r *retrying) backoff(attempt int) time.Duration {
+	d := r.policy.BaseDelay << attempt
+	if d > r.policy.MaxDelay || d <= 0 {
+		d = r.policy.MaxDelay
+	}
+	// Equal jitter: a random delay in [d/2, d] spreads out clients retrying
+	// together while still waiting at least half the backoff
+	return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+type limited struct {
+	Provider
+	slots chan struct{}
+}
+
+// WithConcurrencyLimit allows at most n requests to run at once; further
+// callers wait for a free slot or until their context is done.
+func WithConcurrencyLimit(p Provider, n int) Provider {
+	if n < 1 {
+		n = 1
+	}
+	return &limited{Provider: p, slots: make(chan struct{}, n)}
+}
+
+func (l *limited) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, &Error{Kind: KindTimeout, Message: "timed out waiting for a free AI slot", Err: ctx.Err()}
+	}
+	defer func() { <-l.slots }()
+	return l.Provider.Chat(ctx, req)
+}
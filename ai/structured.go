@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Schema asks the model for structured output conforming to a JSON schema.
+// Providers without structured-output support still get the schema's intent
+// from the prompt; ChatJSON validates the result either way.
+type Schema struct {
+	Name   string          // identifier sent to the provider, e.g. "song_metadata"
+	Schema json.RawMessage // JSON schema for the response object
+}
+
+// ChatJSON sends req, decodes the reply into a T and runs validate on it.
+// validate may normalize the value in place and returns an error for output
+// that cannot be used. On a decode or validation failure the model is shown
+// its answer and the problem, and asked once to correct it.
+func ChatJSON[T any](ctx context.Context, p Provider, req ChatRequest, validate func(*T) error) (*T, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeJSON(resp.Content, validate)
+	if err == nil {
+		return v, nil
+	}
+
+	repair := req
+	repair.Messages = append(append([]Message{}, req.Messages...),
+		Assistant(resp.Content),
+		User(Text(fmt.Sprintf("Your response was invalid: %v. Reply again with ONLY the corrected JSON object in the required format.", err))),
+	)
+	resp, err = p.Chat(ctx, repair)
+	if err != nil {
+		return nil, err
+	}
+	v, err = decodeJSON(resp.Content, validate)
+	if err != nil {
+		return nil, &Error{Kind: KindInvalidResponse, Message: fmt.Sprintf("%v (response: %s)", err, truncate(resp.Content, 500)), Err: err}
+	}
+	return v, nil
+}
+
+func decodeJSON[T any](raw string, validate func(*T) error) (*T, error) {
+	var v T
+	if err := json.Unmarshal([]byte(ExtractJSON(raw)), &v); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %w", err)
+	}
+	if validate != nil {
+		if err := validate(&v); err != nil {
+			return nil, err
+		}
+	}
+	return &v, nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
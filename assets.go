@@ -0,0 +1,25 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Templates and static files are compiled into the binary, so it runs from
+// any working directory.
+//
+//go:embed templates static
+var embeddedAssets embed.FS
+
+// assetFS returns the template and static file systems: the embedded copies,
+// or the files under dir when it is set (development).
+func assetFS(dir string) (templates, static fs.FS) {
+	if dir != "" {
+		return os.DirFS(filepath.Join(dir, "templates")), os.DirFS(filepath.Join(dir, "static"))
+	}
+	templates, _ = fs.Sub(embeddedAssets, "templates")
+	static, _ = fs.Sub(embeddedAssets, "static")
+	return templates, static
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// aiCacheKey identifies an AI request by everything that influences its
+// result, and by the user who made it: cached results are not shared.
+type aiCacheKey struct {
+	UserID     string
+	Kind       string
+	JobID      string
+	PageHashes []string
+	Input      any // request-specific inputs (crops, sections, ...)
+}
+
+func (d *Deps) newAICacheKey(kind, jobID string, pageImages []string, input any) aiCacheKey {
+	hashes := make([]string, len(pageImages))
+	for i, img := range pageImages {
+		hashes[i] = storage.HashBytes([]byte(img))
+	}
+	return aiCacheKey{UserID: d.User.ID, Kind: kind, JobID: jobID, PageHashes: hashes, Input: input}
+}
+
+func (k aiCacheKey) hash(model, promptVersion string) string {
+	input, _ := json.Marshal(k.Input)
+	h := sha256.New()
+	h.Write([]byte(k.UserID + "\n" + k.Kind + "\n" + model + "\n" + promptVersion + "\n" + k.JobID + "\n" + strings.Join(k.PageHashes, ",") + "\n"))
+	h.Write(input)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// cachedAICall returns a cached result for key when one exists (unless force
+// is set), otherwise runs call and stores its result. The X-AI-Cache response
+// header reports "hit" or "miss".
+func cachedAICall[T any](ctx context.Context, d *Deps, w http.ResponseWriter, key aiCacheKey, force bool, call func() (*T, error)) (*T, error) {
+	// Editing a prompt template invalidates results produced with the old one
+	model := d.AI.Model()
+	hash := key.hash(model, d.Prompts.Version(key.Kind+".system", key.Kind+".user"))
+
+	if !force {
+		if entry, err := d.AICache.Get(hash); err == nil && entry != nil && entry.UserID == key.UserID {
+			var cached T
+			if err := json.Unmarshal(entry.Result, &cached); err == nil {
+				w.Header().Set("X-AI-Cache", "hit")
+				return &cached, nil
+			}
+		}
+	}
+
+	result, err := call()
+	if err != nil {
+		return nil, err
+	}
+	w.Header().Set("X-AI-Cache", "miss")
+
+	raw, err := json.Marshal(result)
+	if err == nil {
+		err = d.AICache.Put(models.AICacheEntry{
+			Key:        hash,
+			UserID:     key.UserID,
+			Kind:       key.Kind,
+			JobID:      key.JobID,
+			PageHashes: key.PageHashes,
+			Model:      model,
+			CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+			Result:     raw,
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to cache AI result", "kind", key.Kind, "err", err)
+	}
+	return result, nil
+}
+
+// HandleListAICache lists the user's cached AI results, optionally filtered by ?jobId=.
+func (d *Deps) HandleListAICache(w http.ResponseWriter, r *http.Request) {
+	entries, err := d.AICache.List(d.User.ID, r.URL.Query().Get("jobId"))
+	if err != nil {
+		jsonError(w, "Failed to load AI cache", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.AICacheEntry{}
+	}
+	jsonOK(w, entries)
+}
+
+// HandleClearAICache removes the user's cached AI results for ?jobId=, or all
+// of them.
+func (d *Deps) HandleClearAICache(w http.ResponseWriter, r *http.Request) {
+	removed, err := d.AICache.Clear(d.User.ID, r.URL.Query().Get("jobId"))
+	if err != nil {
+		jsonError(w, "Failed to clear AI cache", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, map[string]any{"success": true, "removed": removed})
+}
+
+// HandleDeleteAICacheEntry removes a single cached AI result.
+func (d *Deps) HandleDeleteAICacheEntry(w http.ResponseWriter, r *http.Request) {
+	if err := d.AICache.Delete(d.User.ID, r.PathValue("key")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			jsonError(w, "Cache entry not found", http.StatusNotFound)
+		} else {
+			jsonError(w, "Failed to delete cache entry", http.StatusInternalServerError)
+		}
+		return
+	}
+	jsonOK(w, map[string]any{"success": true})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LianHaeming/avoidnt/ai"
+)
+
+// aiError writes an AI failure as JSON with a machine-readable "code" so the
+// frontend can tell e.g. an exhausted quota from an image the model rejected.
+func aiError(w http.ResponseWriter, err error) {
+	var aiErr *ai.Error
+	if !errors.As(err, &aiErr) {
+		jsonErrorCode(w, "Analysis failed: "+err.Error(), "internal", http.StatusInternalServerError)
+		return
+	}
+
+	var msg string
+	status := http.StatusBadGateway
+	switch aiErr.Kind {
+	case ai.KindTimeout:
+		msg = "The AI took too long to respond. Please try again."
+		status = http.StatusGatewayTimeout
+	case ai.KindRateLimited:
+		msg = "The AI is busy right now. Please try again in a minute."
+		status = http.StatusTooManyRequests
+	case ai.KindQuota:
+		msg = "The AI quota has been used up. Check the API plan and billing."
+		status = http.StatusPaymentRequired
+	case ai.KindAuth:
+		msg = "The AI provider rejected the API key."
+	case ai.KindBadInput:
+		msg = "The AI could not process these pages: " + aiErr.Message
+		status = http.StatusUnprocessableEntity
+	case ai.KindBudget:
+		msg = "The AI budget has been reached: " + aiErr.Message + ". Try again once it resets or raise the limit."
+		status = http.StatusPaymentRequired
+	case ai.KindUnavailable:
+		msg = "The AI provider is unavailable. Please try again later."
+		status = http.StatusServiceUnavailable
+	default:
+		msg = "Analysis failed: " + aiErr.Message
+	}
+	if aiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", aiErr.RetryAfter.Seconds()))
+	}
+	jsonErrorCode(w, msg, string(aiErr.Kind), status)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/ai"
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// AIUsageMeter records every AI request with an estimated cost and refuses
+// new requests once the daily or monthly budget is spent. It implements
+// ai.Meter. Days and months follow the server's local time.
+type AIUsageMeter struct {
+	Store         *storage.AIUsageStore
+	InputPrice    float64 // USD per million prompt tokens
+	OutputPrice   float64 // USD per million completion tokens
+	DailyBudget   float64 // USD; 0 = unlimited
+	MonthlyBudget float64 // USD; 0 = unlimited
+
+	// Running totals for the budget checks, read from the log once and then
+	// kept up to date by Record, so a check doesn't re-read the whole log.
+	mu        sync.Mutex
+	loaded    bool
+	day       time.Time // start of the day dayCost covers
+	month     time.Time // start of the month monthCost covers
+	dayCost   float64
+	monthCost float64
+}
+
+// AIUsageTotals sums up a set of usage entries.
+type AIUsageTotals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	CostUSD          float64 `json:"costUsd"`
+}
+
+// AIUsageDay is the usage for one calendar day.
+type AIUsageDay struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	AIUsageTotals
+}
+
+// AIUsageReport is returned from GET /api/ai/usage.
+type AIUsageReport struct {
+	Today         AIUsageTotals            `json:"today"`
+	Month         AIUsageTotals            `json:"month"`
+	DailyBudget   float64                  `json:"dailyBudget"`   // 0 = unlimited
+	MonthlyBudget float64                  `json:"monthlyBudget"` // 0 = unlimited
+	InputPrice    float64                  `json:"inputPrice"`    // USD per million tokens
+	OutputPrice   float64                  `json:"outputPrice"`   // USD per million tokens
+	Days          []AIUsageDay             `json:"days"`          // newest first
+	Endpoints     map[string]AIUsageTotals `json:"endpoints"`     // over the reported days
+	Recent        []models.AIUsageEntry    `json:"recent"`        // newest first
+}
+
+func (t *AIUsageTotals) add(e models.AIUsageEntry) {
+	t.Calls++
+	t.PromptTokens += e.PromptTokens
+	t.CompletionTokens += e.CompletionTokens
+	t.TotalTokens += e.TotalTokens
+	t.CostUSD += e.CostUSD
+}
+
+// Cost estimates the price of a response's token usage in USD.
+func (m *AIUsageMeter) Cost(u ai.Usage) float64 {
+	cost := float64(u.PromptTokens)*m.InputPrice/1e6 + float64(u.CompletionTokens)*m.OutputPrice/1e6
+	return math.Round(cost*1e6) / 1e6
+}
+
+// Allow refuses requests once a budget is spent.
+func (m *AIUsageMeter) Allow(ctx context.Context, req ai.ChatRequest) error {
+	if m.DailyBudget <= 0 && m.MonthlyBudget <= 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	err := m.loadLocked(time.Now())
+	today, month := m.dayCost, m.monthCost
+	m.mu.Unlock()
+	if err != nil {
+		// Don't block AI features on an unreadable log
+		slog.ErrorContext(ctx, "AI usage: reading log", "err", err)
+		return nil
+	}
+
+	if m.DailyBudget > 0 && today >= m.DailyBudget {
+		return &ai.Error{Kind: ai.KindBudget, Message: fmt.Sprintf("daily limit of $%.2f reached ($%.2f spent today)", m.DailyBudget, today)}
+	}
+	if m.MonthlyBudget > 0 && month >= m.MonthlyBudget {
+		return &ai.Error{Kind: ai.KindBudget, Message: fmt.Sprintf("monthly limit of $%.2f reached ($%.2f spent this month)", m.MonthlyBudget, month)}
+	}
+	return nil
+}
+
+// loadLocked seeds the running totals from the log on first use and resets
+// them when a new day or month starts; callers hold m.mu.
+func (m *AIUsageMeter) loadLocked(now time.Time) error {
+	sod, som := startOfDay(now), startOfMonth(now)
+	if !m.loaded {
+		entries, err := m.Store.Since(som)
+		if err != nil {
+			return err
+		}
+		today, month := splitUsage(entries, now)
+		m.dayCost, m.monthCost = today.CostUSD, month.CostUSD
+		m.loaded = true
+	}
+	if !m.month.Equal(som) {
+		if !m.month.IsZero() {
+			m.monthCost = 0
+		}
+		m.month = som
+	}
+	if !m.day.Equal(sod) {
+		if !m.day.IsZero() {
+			m.dayCost = 0
+		}
+		m.day = sod
+	}
+	return nil
+}
+
+// Record appends a usage entry for a completed request.
+func (m *AIUsageMeter) Record(ctx context.Context, req ai.ChatRequest, resp *ai.ChatResponse) {
+	endpoint := req.Endpoint
+	if endpoint == "" {
+		endpoint = "other"
+	}
+	entry := models.AIUsageEntry{
+		Time:             time.Now().UTC().Format(time.RFC3339),
+		Endpoint:         endpoint,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		CostUSD:          m.Cost(resp.Usage),
+	}
+	if err := m.Store.Append(entry); err != nil {
+		slog.ErrorContext(ctx, "AI usage: recording call", "endpoint", endpoint, "err", err)
+	}
+
+	// Before the first load the entry is counted when the log is read
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loaded && m.loadLocked(time.Now()) == nil {
+		m.dayCost += entry.CostUSD
+		m.monthCost += entry.CostUSD
+	}
+}
+
+// HandleAIUsage reports AI usage and spending against the budgets.
+// Query: days=N (default 30) limits the per-day and per-endpoint breakdown.
+func (d *Deps) HandleAIUsage(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			jsonError(w, "days must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	m := d.AIUsage
+	now := time.Now()
+	from := startOfDay(now).AddDate(0, 0, -(days - 1))
+	if som := startOfMonth(now); som.Before(from) {
+		from = som
+	}
+	entries, err := m.Store.Since(from)
+	if err != nil {
+		jsonError(w, "Failed to read AI usage", http.StatusInternalServerError)
+		return
+	}
+
+	report := AIUsageReport{
+		DailyBudget:   m.DailyBudget,
+		MonthlyBudget: m.MonthlyBudget,
+		InputPrice:    m.InputPrice,
+		OutputPrice:   m.OutputPrice,
+		Days:          []AIUsageDay{},
+		Endpoints:     map[string]AIUsageTotals{},
+		Recent:        []models.AIUsageEntry{},
+	}
+	report.Today, report.Month = splitUsage(entries, now)
+
+	cutoff := startOfDay(now).AddDate(0, 0, -(days - 1))
+	byDay := map[string]*AIUsageDay{}
+	for _, e := range entries {
+		ts, _ := time.Parse(time.RFC3339, e.Time)
+		ts = ts.Local()
+		if ts.Before(cutoff) {
+			continue
+		}
+		date := ts.Format("2006-01-02")
+		day, ok := byDay[date]
+		if !ok {
+			day = &AIUsageDay{Date: date}
+			byDay[date] = day
+		}
+		day.add(e)
+		ep := report.Endpoints[e.Endpoint]
+		ep.add(e)
+		report.Endpoints[e.Endpoint] = ep
+	}
+	for i := 0; i < days; i++ {
+		date := startOfDay(now).AddDate(0, 0, -i).Format("2006-01-02")
+		if day, ok := byDay[date]; ok {
+			report.Days = append(report.Days, *day)
+		} else {
+			report.Days = append(report.Days, AIUsageDay{Date: date})
+		}
+	}
+	for i := len(entries) - 1; i >= 0 && len(report.Recent) < 50; i-- {
+		report.Recent = append(report.Recent, entries[i])
+	}
+
+	jsonOK(w, report)
+}
+
+// splitUsage totals entries for the current day and month.
+func splitUsage(entries []models.AIUsageEntry, now time.Time) (today, month AIUsageTotals) {
+	sod, som := startOfDay(now), startOfMonth(now)
+	for _, e := range entries {
+		ts, err := time.Parse(time.RFC3339, e.Time)
+		if err != nil {
+			continue
+		}
+		if !ts.Before(som) {
+			month.add(e)
+		}
+		if !ts.Before(sod) {
+			today.add(e)
+		}
+	}
+	return today, month
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
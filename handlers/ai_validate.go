@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxAINameLen caps exercise and section names suggested by the AI.
+const maxAINameLen = 40
+
+// limitName trims s and cuts it to maxAINameLen characters.
+func limitName(s string) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > maxAINameLen {
+		s = strings.TrimSpace(string(r[:maxAINameLen]))
+	}
+	return s
+}
+
+// checkLevel rejects confidence or priority values outside high/medium/low.
+func checkLevel(v string) error {
+	switch v {
+	case "high", "medium", "low":
+		return nil
+	}
+	return fmt.Errorf("%q must be \"high\", \"medium\" or \"low\"", v)
+}
@@ -1,50 +1,65 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/LianHaeming/avoidnt/ai"
 )
 
 // AnalyzeRequest is the JSON body for POST /api/analyze-pdf.
 type AnalyzeRequest struct {
-	PageImages []string `json:"pageImages"`
-	JobID      string   `json:"jobId,omitempty"`
-	PageCount  int      `json:"pageCount,omitempty"`
+	PageImages   []string `json:"pageImages"`
+	JobID        string   `json:"jobId,omitempty"`
+	PageCount    int      `json:"pageCount,omitempty"`
+	ForceRefresh bool     `json:"forceRefresh,omitempty"` // bypass cached results
+	Engine       string   `json:"engine,omitempty"`       // "ai", "ocr", or "" to use AI when configured
 }
 
 // AnalyzeResponse contains extracted song metadata.
 type AnalyzeResponse struct {
-	Title    *string  `json:"title"`
-	Artist   *string  `json:"artist"`
-	Tempo    *int     `json:"tempo"`
-	Sections []string `json:"sections"`
+	Title         *string  `json:"title"`
+	Artist        *string  `json:"artist"`
+	Tempo         *int     `json:"tempo"`
+	Key           *string  `json:"key"`
+	Mode          *string  `json:"mode"`
+	TimeSignature *string  `json:"timeSignature"`
+	Capo          *int     `json:"capo"`
+	Tuning        *string  `json:"tuning"`
+	Sections      []string `json:"sections"`
 }
 
-// HandleAnalyzePDF uses OpenAI vision to extract song metadata from page images.
+// HandleAnalyzePDF uses a vision model to extract song metadata from page
+// images, or local OCR when no AI provider is configured. The
+// X-Analyze-Engine response header reports which one ran.
 func (d *Deps) HandleAnalyzePDF(w http.ResponseWriter, r *http.Request) {
-	if d.OpenAIKey == "" {
-		jsonError(w, "OpenAI API key not configured", http.StatusServiceUnavailable)
-		return
-	}
-
 	var req AnalyzeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	useOCR := req.Engine == "ocr" || (req.Engine == "" && d.AI == nil)
+	switch {
+	case useOCR && d.OCR == nil:
+		jsonError(w, "AI provider not configured and tesseract is not installed", http.StatusServiceUnavailable)
+		return
+	case !useOCR && d.AI == nil:
+		jsonError(w, "AI provider not configured", http.StatusServiceUnavailable)
+		return
+	}
+
 	// If jobId is provided, load images from disk instead
 	var pageImages []string
 	if req.JobID != "" && req.PageCount > 0 {
-		imgs, err := d.loadJobPageImages(req.JobID, req.PageCount)
+		imgs, err := d.loadJobPageImages(req.JobID, req.PageCount, 4)
 		if err != nil {
 			jsonError(w, err.Error(), http.StatusNotFound)
 			return
@@ -64,20 +79,39 @@ func (d *Deps) HandleAnalyzePDF(w http.ResponseWriter, r *http.Request) {
 		pageImages = pageImages[:4]
 	}
 
-	result, err := d.callOpenAIVision(pageImages)
+	if useOCR {
+		w.Header().Set("X-Analyze-Engine", "ocr")
+		result, err := d.OCR.Analyze(r.Context(), pageImages)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "OCR analysis failed", "err", err)
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonOK(w, result)
+		return
+	}
+
+	w.Header().Set("X-Analyze-Engine", "ai")
+	key := d.newAICacheKey("analyze", req.JobID, pageImages, nil)
+	result, err := cachedAICall(r.Context(), d, w, key, req.ForceRefresh, func() (*AnalyzeResponse, error) {
+		return d.callAnalyzeAI(r.Context(), pageImages)
+	})
 	if err != nil {
-		log.Printf("OpenAI analysis failed: %v", err)
-		jsonError(w, "Analysis failed: "+err.Error(), http.StatusBadGateway)
+		slog.ErrorContext(r.Context(), "AI analysis failed", "err", err)
+		aiError(w, err)
 		return
 	}
 
 	jsonOK(w, result)
 }
 
-func (d *Deps) loadJobPageImages(jobID string, pageCount int) ([]string, error) {
-	limit := pageCount
-	if limit > 4 {
-		limit = 4
+// loadJobPageImages loads up to limit page images from disk as data URLs.
+func (d *Deps) loadJobPageImages(jobID string, pageCount, limit int) ([]string, error) {
+	if !d.canReadJob(jobID) {
+		return nil, fmt.Errorf("job not found")
+	}
+	if pageCount < limit {
+		limit = pageCount
 	}
 
 	var images []string
@@ -105,120 +139,104 @@ func (d *Deps) loadJobPageImages(jobID string, pageCount int) ([]string, error)
 	return images, nil
 }
 
-func (d *Deps) callOpenAIVision(pageImages []string) (*AnalyzeResponse, error) {
-	systemPrompt := `You are a music sheet analyzer. You will be given images of sheet music / guitar tablature pages. Extract the following metadata from the sheet music if visible:
-
-1. **title** – The song title
-2. **artist** – The artist / composer / band name
-3. **tempo** – The tempo in BPM (as a number).
-4. **sections** – A list of the song's structural sections visible in the sheet music (e.g., Intro, Verse, Chorus, Bridge, Solo, Outro).
-
-IMPORTANT for sections:
-- Only include sections that are part of the actual song structure / arrangement.
-- Do NOT include "variations", "alternatives", "practice exercises", or "ossia" bars that often appear at the bottom of a page or at the end of the sheet. These are supplementary practice material, not song sections.
-- Look for clear section labels, rehearsal marks, or double barlines that indicate structural divisions.
-- If the same section type appears multiple times (e.g., Verse 1, Verse 2), just include the base name once (e.g., "Verse").
-
-Return ONLY valid JSON in this exact format:
-{
-  "title": "Song Title" or null,
-  "artist": "Artist Name" or null,
-  "tempo": 120 or null,
-  "sections": ["Intro", "Verse", "Chorus"]
-}
-
-If a field is not visible or cannot be determined, use null (or empty array for sections).
-Do not guess — only extract what is clearly visible in the sheet music.`
-
-	// Build image content
-	var imageContent []map[string]any
-	for _, dataURL := range pageImages {
-		if !strings.HasPrefix(dataURL, "data:") {
-			dataURL = "data:image/jpeg;base64," + dataURL
-		}
-		imageContent = append(imageContent, map[string]any{
-			"type": "image_url",
-			"image_url": map[string]any{
-				"url":    dataURL,
-				"detail": "low",
-			},
-		})
-	}
-
-	userContent := []map[string]any{
-		{"type": "text", "text": "Analyze these sheet music pages and extract the song metadata."},
-	}
-	userContent = append(userContent, imageContent...)
-
-	body := map[string]any{
-		"model":       "gpt-4o",
-		"max_tokens":  500,
-		"temperature": 0,
-		"messages": []map[string]any{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userContent},
-		},
-	}
-
-	bodyJSON, _ := json.Marshal(body)
-
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(bodyJSON))
+// callAnalyzeAI asks the vision model to extract song metadata.
+func (d *Deps) callAnalyzeAI(ctx context.Context, pageImages []string) (*AnalyzeResponse, error) {
+	system, user, err := d.renderPrompts("analyze", analyzePromptData{PageCount: len(pageImages)})
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+d.OpenAIKey)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("OpenAI request failed: %w", err)
-	}
-	defer resp.Body.Close()
 
-	respBody, _ := io.ReadAll(resp.Body)
+	return ai.ChatJSON(ctx, d.AI, ai.ChatRequest{
+		Endpoint:    "analyze",
+		MaxTokens:   500,
+		Temperature: 0,
+		Schema:      &ai.Schema{Name: "song_metadata", Schema: json.RawMessage(analyzeSchema)},
+		Messages: []ai.Message{
+			ai.System(system),
+			ai.User(append([]ai.Part{ai.Text(user)}, pageImageParts(pageImages)...)...),
+		},
+	}, validateAnalyze)
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("OpenAI returned %d: %s", resp.StatusCode, string(respBody))
+const analyzeSchema = `{
+  "type": "object",
+  "properties": {
+    "title": {"type": ["string", "null"]},
+    "artist": {"type": ["string", "null"]},
+    "tempo": {"type": ["integer", "null"]},
+    "key": {"type": ["string", "null"]},
+    "mode": {"type": ["string", "null"]},
+    "timeSignature": {"type": ["string", "null"]},
+    "capo": {"type": ["integer", "null"]},
+    "tuning": {"type": ["string", "null"]},
+    "sections": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["title", "artist", "tempo", "key", "mode", "timeSignature", "capo", "tuning", "sections"],
+  "additionalProperties": false
+}`
+
+// validateAnalyze normalizes extracted metadata: blank strings become null,
+// implausible tempos and unparseable musical fields are dropped, and section
+// names are deduplicated.
+func validateAnalyze(res *AnalyzeResponse) error {
+	res.Title = nonBlank(res.Title)
+	res.Artist = nonBlank(res.Artist)
+	if res.Tempo != nil && (*res.Tempo < 20 || *res.Tempo > 400) {
+		res.Tempo = nil
+	}
+
+	// Same rules as the save API, but a field that fails them is dropped
+	// rather than failing the whole analysis.
+	if res.Mode != nil {
+		mode, _ := normalizeMode(*res.Mode)
+		res.Mode = optString(mode)
+	}
+	if res.Key != nil {
+		key, mode, _ := normalizeKey(*res.Key)
+		res.Key = optString(key)
+		if res.Mode == nil {
+			res.Mode = optString(mode)
+		}
 	}
-
-	var openAIResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+	if res.TimeSignature != nil {
+		ts, _ := normalizeTimeSignature(*res.TimeSignature)
+		res.TimeSignature = optString(ts)
 	}
-
-	if err := json.Unmarshal(respBody, &openAIResp); err != nil {
-		return nil, fmt.Errorf("failed to parse OpenAI response: %w", err)
+	if res.Capo != nil && (*res.Capo < 1 || *res.Capo > 24) {
+		res.Capo = nil
 	}
-
-	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("OpenAI returned no choices")
+	if res.Tuning != nil {
+		res.Tuning = optString(limitName(*res.Tuning))
 	}
 
-	raw := strings.TrimSpace(openAIResp.Choices[0].Message.Content)
-
-	// Strip markdown code fences if present
-	jsonStr := raw
-	if strings.HasPrefix(jsonStr, "```") {
-		if idx := strings.Index(jsonStr, "\n"); idx >= 0 {
-			jsonStr = jsonStr[idx+1:]
+	seen := map[string]bool{}
+	sections := []string{}
+	for _, name := range res.Sections {
+		name = limitName(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
 		}
+		seen[strings.ToLower(name)] = true
+		sections = append(sections, name)
 	}
-	if strings.HasSuffix(jsonStr, "```") {
-		jsonStr = jsonStr[:len(jsonStr)-3]
-	}
-	jsonStr = strings.TrimSpace(jsonStr)
+	res.Sections = sections
+	return nil
+}
 
-	var parsed AnalyzeResponse
-	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
-		return nil, fmt.Errorf("AI returned invalid JSON: %s", raw)
+// nonBlank returns nil for a nil or whitespace-only string.
+func nonBlank(s *string) *string {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil
 	}
+	t := strings.TrimSpace(*s)
+	return &t
+}
 
-	if parsed.Sections == nil {
-		parsed.Sections = []string{}
+// pageImageParts converts page data URLs into low-detail image parts.
+func pageImageParts(pageImages []string) []ai.Part {
+	parts := make([]ai.Part, len(pageImages))
+	for i, dataURL := range pageImages {
+		parts[i] = ai.Image(dataURL, "low")
 	}
-
-	return &parsed, nil
+	return parts
 }
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
 )
 
 // HandleSaveSong creates or updates a song (JSON body).
@@ -27,13 +28,44 @@ func (d *Deps) HandleSaveSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Preserve practice data from existing song
-	existing, _ := d.Songs.Get(song.ID)
-	if existing != nil {
+	if id := models.InvalidSongID(&song); id != "" {
+		jsonError(w, fmt.Sprintf("Invalid ID %q: use letters, digits, - and _ only", id), http.StatusBadRequest)
+		return
+	}
+
+	if err := normalizeSongMeta(&song); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Sharing state is managed by its own endpoints
+	song.SharedReadOnly = false
+	song.Assignment = nil
+
+	revs, err := ifMatchRevisions(r)
+	if err != nil {
+		jsonError(w, "Invalid If-Match header", http.StatusBadRequest)
+		return
+	}
+
+	// Preserve practice data from the existing song. The merge runs under
+	// the store lock so a concurrent PATCH can't be lost in between.
+	err = d.Songs.Put(&song, func(existing *models.Song) error {
+		if existing == nil {
+			if revs != nil {
+				return &songWriteError{"Song not found", http.StatusPreconditionFailed}
+			}
+			return nil
+		}
+		if !revisionMatches(existing, revs) {
+			return &conflictError{current: *existing}
+		}
 		// Preserve display settings
 		if song.CropBgColor == nil && existing.CropBgColor != nil {
 			song.CropBgColor = existing.CropBgColor
 		}
+		song.SharedReadOnly = existing.SharedReadOnly
+		song.Assignment = existing.Assignment
 		existingExMap := map[string]*models.Exercise{}
 		for i := range existing.Exercises {
 			existingExMap[existing.Exercises[i].ID] = &existing.Exercises[i]
@@ -50,14 +82,16 @@ func (d *Deps) HandleSaveSong(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
-	}
-
-	if err := d.Songs.Save(&song); err != nil {
-		jsonError(w, "Failed to save song: "+err.Error(), http.StatusInternalServerError)
+		return nil
+	})
+	if err != nil {
+		d.songWriteFailed(w, r, song.ID, err)
 		return
 	}
 
-	jsonOK(w, map[string]any{"success": true, "songId": song.ID})
+	d.publish(Event{Type: EventSong, SongID: song.ID, Revision: song.Revision})
+	w.Header().Set("ETag", song.ETag())
+	jsonOK(w, map[string]any{"success": true, "songId": song.ID, "revision": song.Revision})
 }
 
 // HandleDeleteSong deletes a song.
@@ -73,6 +107,7 @@ func (d *Deps) HandleDeleteSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	d.publish(Event{Type: EventSongDeleted, SongID: songID})
 	jsonOK(w, map[string]any{"success": true})
 }
 
@@ -98,67 +133,60 @@ func (d *Deps) HandlePatchExercise(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	song, err := d.Songs.Get(songID)
-	if err != nil || song == nil {
-		jsonError(w, "Song not found", http.StatusNotFound)
-		return
-	}
-
-	found := false
-	for i := range song.Exercises {
-		ex := &song.Exercises[i]
-		if ex.ID == exerciseID {
-			if req.Stage != nil && *req.Stage != ex.Stage {
-				ex.Stage = *req.Stage
-				// Append to stage log
-				d.StageLogs.Append(songID, models.StageLogEntry{
-					ExerciseID: exerciseID,
-					Stage:      *req.Stage,
-					Timestamp:  time.Now().UTC().Format(time.RFC3339),
-				})
-			}
-			if req.TotalPracticedSeconds != nil {
-				ex.TotalPracticedSeconds = *req.TotalPracticedSeconds
-			}
-			if req.TotalReps != nil {
-				ex.TotalReps = *req.TotalReps
-			}
-			if req.LastPracticedAt != nil {
-				ex.LastPracticedAt = req.LastPracticedAt
-			}
-			if req.CropScale != nil {
-				ex.CropScale = req.CropScale
-			}
-			if req.CropAlign != nil {
-				if *req.CropAlign == "left" || *req.CropAlign == "" {
-					ex.CropAlign = nil
-				} else {
-					ex.CropAlign = req.CropAlign
-				}
+	var stageChanged bool
+	song := d.updateSong(w, r, songID, func(song *models.Song) error {
+		ex := song.FindExercise(exerciseID)
+		if ex == nil {
+			return &songWriteError{"Exercise not found", http.StatusNotFound}
+		}
+		if req.Stage != nil && *req.Stage != ex.Stage {
+			ex.Stage = *req.Stage
+			stageChanged = true
+		}
+		if req.TotalPracticedSeconds != nil {
+			ex.TotalPracticedSeconds = *req.TotalPracticedSeconds
+		}
+		if req.TotalReps != nil {
+			ex.TotalReps = *req.TotalReps
+		}
+		if req.LastPracticedAt != nil {
+			ex.LastPracticedAt = req.LastPracticedAt
+		}
+		if req.CropScale != nil {
+			ex.CropScale = req.CropScale
+		}
+		if req.CropAlign != nil {
+			if *req.CropAlign == "left" || *req.CropAlign == "" {
+				ex.CropAlign = nil
+			} else {
+				ex.CropAlign = req.CropAlign
 			}
-			if req.CropFit != nil {
-				if *req.CropFit {
-					ex.CropFit = req.CropFit
-				} else {
-					ex.CropFit = nil
-				}
+		}
+		if req.CropFit != nil {
+			if *req.CropFit {
+				ex.CropFit = req.CropFit
+			} else {
+				ex.CropFit = nil
 			}
-			found = true
-			break
 		}
-	}
-
-	if !found {
-		jsonError(w, "Exercise not found", http.StatusNotFound)
+		return nil
+	})
+	if song == nil {
 		return
 	}
 
-	if err := d.Songs.Save(song); err != nil {
-		jsonError(w, "Failed to save", http.StatusInternalServerError)
-		return
+	d.publish(Event{Type: EventExercise, SongID: songID, Revision: song.Revision, Data: song.FindExercise(exerciseID)})
+	if stageChanged {
+		entry := models.StageLogEntry{
+			ExerciseID: exerciseID,
+			Stage:      *req.Stage,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		}
+		d.StageLogs.Append(songID, entry)
+		d.publish(Event{Type: EventStageLog, SongID: songID, Data: entry})
 	}
 
-	jsonOK(w, map[string]any{"success": true})
+	jsonOK(w, map[string]any{"success": true, "revision": song.Revision})
 }
 
 // PatchSongDisplayRequest is the JSON body for PATCH song display settings.
@@ -181,104 +209,114 @@ func (d *Deps) HandlePatchSongDisplay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	song, err := d.Songs.Get(songID)
-	if err != nil || song == nil {
-		jsonError(w, "Song not found", http.StatusNotFound)
-		return
-	}
-
-	if req.CropBgColor != nil {
-		if *req.CropBgColor == "" {
-			song.CropBgColor = nil
-		} else {
-			song.CropBgColor = req.CropBgColor
+	song := d.updateSong(w, r, songID, func(song *models.Song) error {
+		if req.CropBgColor != nil {
+			if *req.CropBgColor == "" {
+				song.CropBgColor = nil
+			} else {
+				song.CropBgColor = req.CropBgColor
+			}
 		}
-	}
-	if req.HideTitles != nil {
-		song.HideTitles = *req.HideTitles
-	}
-	if req.HideControls != nil {
-		song.HideControls = *req.HideControls
-	}
-	if req.HideDividers != nil {
-		song.HideDividers = *req.HideDividers
-	}
-	if req.HideStages != nil {
-		song.HideStages = *req.HideStages
-	}
-	if req.HideCards != nil {
-		song.HideCards = *req.HideCards
-	}
-
-	if err := d.Songs.Save(song); err != nil {
-		jsonError(w, "Failed to save", http.StatusInternalServerError)
+		if req.HideTitles != nil {
+			song.HideTitles = *req.HideTitles
+		}
+		if req.HideControls != nil {
+			song.HideControls = *req.HideControls
+		}
+		if req.HideDividers != nil {
+			song.HideDividers = *req.HideDividers
+		}
+		if req.HideStages != nil {
+			song.HideStages = *req.HideStages
+		}
+		if req.HideCards != nil {
+			song.HideCards = *req.HideCards
+		}
+		return nil
+	})
+	if song == nil {
 		return
 	}
 
-	jsonOK(w, map[string]any{"success": true})
+	d.publish(Event{Type: EventSong, SongID: songID, Revision: song.Revision})
+
+	jsonOK(w, map[string]any{"success": true, "revision": song.Revision})
 }
 
-// HandleRegeneratePreviews re-crops all exercise previews from source page images
-// at full resolution. This improves quality for songs created with older/lower-res settings.
-func (d *Deps) HandleRegeneratePreviews(w http.ResponseWriter, r *http.Request) {
+// HandlePreview serves a crop preview image.
+// Requests carrying ?v={previewHash} are cached as immutable; others revalidate via ETag.
+// A ?w= width and an Accept header listing image/webp select a smaller or
+// compressed variant instead of the PNG master.
+func (d *Deps) HandlePreview(w http.ResponseWriter, r *http.Request) {
 	songID := r.PathValue("songId")
+	cropID := r.PathValue("cropId")
 
-	song, err := d.Songs.Get(songID)
-	if err != nil || song == nil {
-		jsonError(w, "Song not found", http.StatusNotFound)
-		return
-	}
-
-	if song.JobID == "" {
-		jsonError(w, "Song has no source pages", http.StatusBadRequest)
+	path := d.Songs.PreviewPath(songID, cropID)
+	masterHash, err := storage.FileHash(path)
+	if err != nil {
+		http.NotFound(w, r)
 		return
 	}
 
-	regenerated := 0
-	for i := range song.Exercises {
-		for j := range song.Exercises[i].Crops {
-			crop := &song.Exercises[i].Crops[j]
-			pageNum := crop.PageIndex + 1 // pageIndex is 0-based
-			pagePath, err := d.Jobs.GetPagePath(song.JobID, pageNum)
-			if err != nil {
-				log.Printf("Skip crop %s: page %d not found: %v", crop.ID, pageNum, err)
-				continue
-			}
-
-			if err := cropAndSave(pagePath, crop.Rect, d.Songs.PreviewPath(songID, crop.ID)); err != nil {
-				log.Printf("Failed to regenerate crop %s: %v", crop.ID, err)
-				continue
-			}
-			regenerated++
+	contentType := "image/png"
+	if width, ext, ok := d.Previews.negotiatePreview(r); ok {
+		variant, err := d.previewVariant(songID, cropID, width, ext)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Preview variant failed, serving master", "crop", cropID, "err", err)
+		} else {
+			path = variant
+			contentType = previewVariantContentType(ext)
 		}
 	}
 
-	jsonOK(w, map[string]any{"success": true, "regenerated": regenerated})
-}
-
-// HandlePreview serves a crop preview image.
-func (d *Deps) HandlePreview(w http.ResponseWriter, r *http.Request) {
-	songID := r.PathValue("songId")
-	cropID := r.PathValue("cropId")
-
-	data, err := d.Songs.GetPreview(songID, cropID)
+	hash, err := storage.FileHash(path)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Cache-Control", "public, max-age=86400")
-	w.Write(data)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	if r.URL.Query().Get("v") == masterHash {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeFile(w, r, path)
+}
+
+// canReadJob reports whether the signed-in user may see a conversion job's
+// pages: they uploaded it or opened a shared song using it, or a song in
+// their library uses it (such as a copy assigned by a teacher).
+func (d *Deps) canReadJob(jobID string) bool {
+	if !models.ValidID(jobID) {
+		return false
+	}
+	if d.Jobs.HasAccess(jobID, d.User.ID) {
+		return true
+	}
+	songs, err := d.Songs.ListAll()
+	if err != nil {
+		return false
+	}
+	for _, s := range songs {
+		if s.JobID == jobID {
+			d.Jobs.Grant(jobID, d.User.ID)
+			return true
+		}
+	}
+	return false
 }
 
 // HandleGetPage serves a converted PDF page image.
+// Page URLs are scoped to a job ID and never change, so they are cached as immutable.
 func (d *Deps) HandleGetPage(w http.ResponseWriter, r *http.Request) {
 	jobID := r.PathValue("jobId")
 	pageNumStr := r.PathValue("pageNum")
 
 	pageNum, err := strconv.Atoi(pageNumStr)
-	if err != nil || pageNum < 1 {
+	if err != nil || pageNum < 1 || !d.canReadJob(jobID) {
 		http.NotFound(w, r)
 		return
 	}
@@ -289,16 +327,57 @@ func (d *Deps) HandleGetPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hash, err := storage.FileHash(pagePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
 	ext := strings.ToLower(filepath.Ext(pagePath))
 	if ext == ".jpg" || ext == ".jpeg" {
 		w.Header().Set("Content-Type", "image/jpeg")
 	} else {
 		w.Header().Set("Content-Type", "image/png")
 	}
-	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
 	http.ServeFile(w, r, pagePath)
 }
 
+// setContentCacheHeaders sets a strong ETag from a content hash. When the
+// request URL is versioned with the same hash (?v=), the response is cached
+// as immutable; otherwise browsers must revalidate with If-None-Match.
+// http.ServeFile answers matching conditional requests with 304.
+func setContentCacheHeaders(w http.ResponseWriter, r *http.Request, hash string) {
+	w.Header().Set("ETag", `"`+hash+`"`)
+	if r.URL.Query().Get("v") == hash {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// jobInfo describes a conversion job and its page image URLs. Requests made
+// through /api/v1 get v1 page URLs so bearer tokens keep working for them.
+func jobInfo(r *http.Request, jobID string, pageCount int) map[string]any {
+	pageURL := "/api/pages/%s/%d"
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		pageURL = "/api/v1/jobs/%s/pages/%d"
+	}
+	pages := make([]map[string]any, pageCount)
+	for i := 0; i < pageCount; i++ {
+		pages[i] = map[string]any{
+			"pageNum": i + 1,
+			"url":     fmt.Sprintf(pageURL, jobID, i+1),
+		}
+	}
+	return map[string]any{
+		"id":        jobID,
+		"pageCount": pageCount,
+		"pages":     pages,
+	}
+}
+
 // HandleConvertPDF converts an uploaded PDF to page images using mutool.
 func (d *Deps) HandleConvertPDF(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form (max 50MB)
@@ -327,6 +406,9 @@ func (d *Deps) HandleConvertPDF(w http.ResponseWriter, r *http.Request) {
 
 	jobID := generateID()
 	jobDir, err := d.Jobs.CreateJobDir(jobID)
+	if err == nil {
+		err = d.Jobs.Grant(jobID, d.User.ID)
+	}
 	if err != nil {
 		jsonError(w, "Failed to create job directory", http.StatusInternalServerError)
 		return
@@ -334,24 +416,12 @@ func (d *Deps) HandleConvertPDF(w http.ResponseWriter, r *http.Request) {
 
 	pageCount, err := convertPDF(pdfBytes, jobDir)
 	if err != nil {
-		log.Printf("PDF conversion failed: %v", err)
+		slog.ErrorContext(r.Context(), "PDF conversion failed", "err", err)
 		jsonError(w, "PDF conversion failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	pages := make([]map[string]any, pageCount)
-	for i := 0; i < pageCount; i++ {
-		pages[i] = map[string]any{
-			"pageNum": i + 1,
-			"url":     fmt.Sprintf("/api/pages/%s/%d", jobID, i+1),
-		}
-	}
-
-	jsonOK(w, map[string]any{
-		"id":        jobID,
-		"pageCount": pageCount,
-		"pages":     pages,
-	})
+	jsonOK(w, jobInfo(r, jobID, pageCount))
 }
 
 // --- JSON helpers ---
@@ -366,3 +436,10 @@ func jsonError(w http.ResponseWriter, msg string, code int) {
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]string{"error": msg})
 }
+
+// jsonErrorCode is jsonError plus a machine-readable error code.
+func jsonErrorCode(w http.ResponseWriter, msg, errCode string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg, "code": errCode})
+}
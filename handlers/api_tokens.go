@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+const maxTokenNameLen = 60
+
+// APITokenInfo describes a token without its secret.
+type APITokenInfo struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Prefix     string  `json:"prefix"`
+	CreatedAt  string  `json:"createdAt"`
+	LastUsedAt *string `json:"lastUsedAt"`
+}
+
+func tokenInfo(t models.APIToken) APITokenInfo {
+	return APITokenInfo{ID: t.ID, Name: t.Name, Prefix: t.Prefix, CreatedAt: t.CreatedAt, LastUsedAt: t.LastUsedAt}
+}
+
+// HandleListTokens returns the current user's API tokens.
+func (d *Deps) HandleListTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := d.Tokens.List(d.User.ID)
+	if err != nil {
+		jsonError(w, "Failed to load tokens", http.StatusInternalServerError)
+		return
+	}
+	result := make([]APITokenInfo, len(tokens))
+	for i, t := range tokens {
+		result[i] = tokenInfo(t)
+	}
+	jsonOK(w, result)
+}
+
+// CreateTokenRequest is the JSON body for POST /api/tokens.
+type CreateTokenRequest struct {
+	Name string `json:"name"`
+}
+
+// HandleCreateToken issues a new API token. The secret is only in this response.
+func (d *Deps) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxTokenNameLen {
+		jsonError(w, "name must be 1-60 characters", http.StatusBadRequest)
+		return
+	}
+
+	token, t, err := d.Tokens.Create(d.User.ID, generateID(), name)
+	if err != nil {
+		jsonError(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]any{"success": true, "token": token, "info": tokenInfo(*t)})
+}
+
+// HandleDeleteToken revokes one of the current user's API tokens.
+func (d *Deps) HandleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	if err := d.Tokens.Delete(d.User.ID, r.PathValue("tokenId")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			jsonError(w, "Token not found", http.StatusNotFound)
+		} else {
+			jsonError(w, "Failed to revoke token", http.StatusInternalServerError)
+		}
+		return
+	}
+	jsonOK(w, map[string]any{"success": true})
+}
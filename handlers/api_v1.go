@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// openAPISpec documents the /api/v1 surface. Keep it in sync with the v1
+// routes in main.go.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// HandleOpenAPI serves the OpenAPI document for /api/v1.
+func (d *Deps) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// HandleV1Me returns the account the request is authenticated as.
+func (d *Deps) HandleV1Me(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, AccountInfo{ID: d.User.ID, Username: d.User.Username})
+}
+
+// HandleV1ListSongs returns summaries of every song in the library.
+func (d *Deps) HandleV1ListSongs(w http.ResponseWriter, r *http.Request) {
+	songs, err := d.Songs.ListAll()
+	if err != nil {
+		jsonError(w, "Failed to load songs", http.StatusInternalServerError)
+		return
+	}
+	summaries := make([]models.SongSummary, len(songs))
+	for i := range songs {
+		summaries[i] = songs[i].ToSummary()
+	}
+	jsonOK(w, summaries)
+}
+
+// v1Song loads the song named by the {songId} path value, writing a 404 or
+// 500 and returning nil when it can't.
+func (d *Deps) v1Song(w http.ResponseWriter, r *http.Request) *models.Song {
+	song, err := d.Songs.Get(r.PathValue("songId"))
+	if err != nil {
+		jsonError(w, "Failed to load song", http.StatusInternalServerError)
+		return nil
+	}
+	if song == nil {
+		jsonError(w, "Song not found", http.StatusNotFound)
+		return nil
+	}
+	return song
+}
+
+// HandleV1GetSong returns a full song, with its revision as the ETag.
+func (d *Deps) HandleV1GetSong(w http.ResponseWriter, r *http.Request) {
+	if song := d.v1Song(w, r); song != nil {
+		w.Header().Set("ETag", song.ETag())
+		jsonOK(w, song)
+	}
+}
+
+// HandleV1ListExercises returns a song's exercises.
+func (d *Deps) HandleV1ListExercises(w http.ResponseWriter, r *http.Request) {
+	if song := d.v1Song(w, r); song != nil {
+		jsonOK(w, song.Exercises)
+	}
+}
+
+// HandleV1GetJob returns a conversion job's page count and page URLs.
+func (d *Deps) HandleV1GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+	pageCount := d.Jobs.GetPageCount(jobID)
+	if pageCount == 0 || !d.canReadJob(jobID) {
+		jsonError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, jobInfo(r, jobID, pageCount))
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+const sessionCookie = "avoidnt_session"
+
+// userCookie holds the signed-in user's ID for scripts (it is not a
+// credential), so data kept in the browser, like the offline sync queue, is
+// kept per account.
+const userCookie = "avoidnt_user"
+
+// Password hashes are stored as "pbkdf2-sha256$iterations$salt$key" (base64).
+const (
+	passwordIterations = 600_000
+	passwordSaltLen    = 16
+	passwordKeyLen     = 32
+	minPasswordLen     = 8
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{2,31}$`)
+
+type ctxKey int
+
+const (
+	userCtxKey ctxKey = iota
+	requestInfoKey
+)
+
+// UserFrom returns the signed-in user for a request, or nil.
+func UserFrom(ctx context.Context) *models.User {
+	u, _ := ctx.Value(userCtxKey).(*models.User)
+	return u
+}
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLen)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter < 1 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummyPasswordHash is checked against when a username doesn't exist, so a
+// failed login takes the same time either way.
+var dummyPasswordHash, _ = hashPassword("not-a-real-password")
+
+// isPublicPath reports whether a path can be reached without signing in.
+func isPublicPath(path string) bool {
+	return strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/shared/") ||
+		path == "/login" || path == "/register" || path == "/logout" ||
+		path == "/api/v1/openapi.json" || path == "/sw.js"
+}
+
+// tokenUser resolves an "Authorization: Bearer" API token to its user.
+func (d *Deps) tokenUser(r *http.Request) *models.User {
+	auth := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil
+	}
+	t, err := d.Tokens.Lookup(strings.TrimSpace(token))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Token lookup failed", "err", err)
+		return nil
+	}
+	if t == nil {
+		return nil
+	}
+	user, err := d.Users.Get(t.UserID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "User lookup failed", "err", err)
+		return nil
+	}
+	return user
+}
+
+// sessionUser resolves the session cookie on a request to its user.
+func (d *Deps) sessionUser(r *http.Request) *models.User {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	sess, err := d.Sessions.Lookup(c.Value)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Session lookup failed", "err", err)
+		return nil
+	}
+	if sess == nil {
+		return nil
+	}
+	user, err := d.Users.Get(sess.UserID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "User lookup failed", "err", err)
+		return nil
+	}
+	return user
+}
+
+// RequireAuth wraps the router: requests without a valid session are sent to
+// the login page (pages) or rejected with 401 (API). /api/v1 also accepts
+// personal API tokens. The signed-in user is stored in the request context
+// for Scoped handlers.
+func (d *Deps) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user *models.User
+		if strings.HasPrefix(r.URL.Path, "/api/v1/") && r.Header.Get("Authorization") != "" {
+			// A bad token is an error, not a fallback to the session cookie
+			if user = d.tokenUser(r); user == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="avoidnt"`)
+				jsonError(w, "Invalid API token", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			user = d.sessionUser(r)
+			if user != nil {
+				setUserCookie(w, r, user.ID)
+			}
+		}
+		if user == nil && !isPublicPath(r.URL.Path) {
+			if strings.HasPrefix(r.URL.Path, "/api/") || r.Method != http.MethodGet {
+				jsonError(w, "Sign in required", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+		if user != nil {
+			r = r.WithContext(context.WithValue(r.Context(), userCtxKey, user))
+			if info := requestInfoFrom(r.Context()); info != nil {
+				info.userID = user.ID
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setUserCookie sets the userCookie to id, unless the browser already has it.
+func setUserCookie(w http.ResponseWriter, r *http.Request, id string) {
+	if c, err := r.Cookie(userCookie); err == nil && c.Value == id {
+		return
+	}
+	c := &http.Cookie{
+		Name:     userCookie,
+		Value:    id,
+		Path:     "/",
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if id == "" {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+}
+
+// Scoped adapts a handler method so it runs against the signed-in user's
+// library. Use it for every route behind RequireAuth:
+//
+//	mux.HandleFunc("GET /songs", deps.Scoped((*handlers.Deps).HandleSongsList))
+func (d *Deps) Scoped(h func(*Deps, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := UserFrom(r.Context())
+		if user == nil {
+			jsonError(w, "Sign in required", http.StatusUnauthorized)
+			return
+		}
+		if !validPathIDs(r) {
+			jsonError(w, "Not found", http.StatusNotFound)
+			return
+		}
+		h(d.forUser(user), w, r)
+	}
+}
+
+// idPathValues are the route wildcards that name stored objects. They are
+// decoded (so %2F becomes "/") and end up in file paths.
+var idPathValues = []string{"songId", "exerciseId", "cropId", "jobId", "userId", "linkId", "tokenId"}
+
+// validPathIDs reports whether every ID wildcard in the request's route is a
+// valid ID.
+func validPathIDs(r *http.Request) bool {
+	for _, name := range idPathValues {
+		if v := r.PathValue(name); v != "" && !models.ValidID(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// forUser returns a copy of the dependencies with the user's library stores.
+func (d *Deps) forUser(user *models.User) *Deps {
+	lib := d.Libraries.For(user)
+	scoped := *d
+	scoped.User = user
+	scoped.Songs = lib.Songs
+	scoped.Settings = lib.Settings
+	scoped.DailyLogs = lib.DailyLogs
+	scoped.StageLogs = lib.StageLogs
+	scoped.Coach = lib.Coach
+	scoped.SyncOps = lib.SyncOps
+	return &scoped
+}
+
+// AuthPageData is the template data for the login and register pages.
+type AuthPageData struct {
+	Settings    models.UserSettings
+	Register    bool // register form instead of login
+	CanRegister bool
+	Username    string
+	Next        string
+	Error       string
+}
+
+// safeNext only allows local redirect targets after signing in.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/songs"
+	}
+	return next
+}
+
+// canRegister reports whether new accounts may be created. The first account
+// can always be created so a fresh deployment isn't locked out.
+func (d *Deps) canRegister() bool {
+	if d.AllowRegistration {
+		return true
+	}
+	n, err := d.Users.Count()
+	return err == nil && n == 0
+}
+
+func (d *Deps) renderAuth(w http.ResponseWriter, r *http.Request, data AuthPageData, code int) {
+	data.Settings = models.DefaultSettings()
+	data.CanRegister = d.canRegister()
+	data.Next = safeNext(data.Next)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := d.Templates.ExecuteTemplate(w, "auth.html", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template error", "template", "auth.html", "err", err)
+	}
+}
+
+// startSession signs a user in on this browser and redirects to next.
+func (d *Deps) startSession(w http.ResponseWriter, r *http.Request, user *models.User, next string) {
+	token, expires, err := d.Sessions.Create(user.ID, d.SessionTTL)
+	if err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, safeNext(next), http.StatusSeeOther)
+}
+
+// HandleLoginPage renders the sign-in form.
+func (d *Deps) HandleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if d.sessionUser(r) != nil {
+		http.Redirect(w, r, safeNext(r.URL.Query().Get("next")), http.StatusFound)
+		return
+	}
+	n, _ := d.Users.Count()
+	if n == 0 {
+		http.Redirect(w, r, "/register", http.StatusFound)
+		return
+	}
+	d.renderAuth(w, r, AuthPageData{Next: r.URL.Query().Get("next")}, http.StatusOK)
+}
+
+// HandleLogin checks a username and password (form post) and starts a session.
+func (d *Deps) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	username := strings.ToLower(strings.TrimSpace(r.FormValue("username")))
+	password := r.FormValue("password")
+	next := r.FormValue("next")
+
+	user, err := d.Users.GetByUsername(username)
+	if err != nil {
+		http.Error(w, "Failed to load account", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		checkPassword(dummyPasswordHash, password)
+	}
+	if user == nil || !checkPassword(user.PasswordHash, password) {
+		d.renderAuth(w, r, AuthPageData{Username: username, Next: next, Error: "Wrong username or password"}, http.StatusUnauthorized)
+		return
+	}
+	d.startSession(w, r, user, next)
+}
+
+// HandleRegisterPage renders the create-account form.
+func (d *Deps) HandleRegisterPage(w http.ResponseWriter, r *http.Request) {
+	if !d.canRegister() {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	d.renderAuth(w, r, AuthPageData{Register: true, Next: r.URL.Query().Get("next")}, http.StatusOK)
+}
+
+// HandleRegister creates an account (form post) and signs it in.
+func (d *Deps) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	username := strings.ToLower(strings.TrimSpace(r.FormValue("username")))
+	password := r.FormValue("password")
+	next := r.FormValue("next")
+	fail := func(msg string, code int) {
+		d.renderAuth(w, r, AuthPageData{Register: true, Username: username, Next: next, Error: msg}, code)
+	}
+
+	if !d.canRegister() {
+		fail("Registration is closed", http.StatusForbidden)
+		return
+	}
+	if !usernamePattern.MatchString(username) {
+		fail("Username must be 3-32 characters: letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return
+	}
+	if len(password) < minPasswordLen {
+		fail(fmt.Sprintf("Password must be at least %d characters", minPasswordLen), http.StatusBadRequest)
+		return
+	}
+	if password != r.FormValue("confirm") {
+		fail("Passwords don't match", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	user := &models.User{
+		ID:           generateID(),
+		Username:     username,
+		PasswordHash: hash,
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := d.Users.Create(user); err != nil {
+		if errors.Is(err, storage.ErrUsernameTaken) {
+			fail("That username is taken", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create account", http.StatusInternalServerError)
+		return
+	}
+	if user.Legacy {
+		slog.InfoContext(r.Context(), "Account created; it owns the existing library", "user", user.Username)
+	}
+	d.startSession(w, r, user, next)
+}
+
+// HandleLogout ends the current session.
+func (d *Deps) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if err := d.Sessions.Delete(c.Value); err != nil {
+			slog.WarnContext(r.Context(), "Could not delete session", "err", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	setUserCookie(w, r, "")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
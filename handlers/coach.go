@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/ai"
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// coachHistoryDays is how far back daily practice is summarized for the coach.
+const coachHistoryDays = 42
+
+// HandleCoach asks the AI for practice advice based on a song's history and
+// stores the report with the song.
+func (d *Deps) HandleCoach(w http.ResponseWriter, r *http.Request) {
+	if d.AI == nil {
+		jsonError(w, "AI provider not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	songID := r.PathValue("songId")
+	song, err := d.Songs.Get(songID)
+	if err != nil || song == nil {
+		jsonError(w, "Song not found", http.StatusNotFound)
+		return
+	}
+	if len(song.Exercises) == 0 {
+		jsonError(w, "Song has no exercises to coach on", http.StatusBadRequest)
+		return
+	}
+
+	history, err := d.songCoachHistory(song)
+	if err != nil {
+		jsonError(w, "Failed to load practice history", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := d.callCoachAI(r.Context(), song, history)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "AI coach failed", "song", songID, "err", err)
+		aiError(w, err)
+		return
+	}
+
+	if err := d.Coach.Add(songID, *report); err != nil {
+		jsonError(w, "Failed to save coach report", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, report)
+}
+
+// HandleListCoachReports returns a song's stored coach reports, newest first.
+func (d *Deps) HandleListCoachReports(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+	if song, _ := d.Songs.Get(songID); song == nil {
+		jsonError(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	reports, err := d.Coach.GetAll(songID)
+	if err != nil {
+		jsonError(w, "Failed to load coach reports", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, reports)
+}
+
+// songCoachHistory loads a song's practice logs and describes them for the coach.
+func (d *Deps) songCoachHistory(song *models.Song) (string, error) {
+	dailyLogs, err := d.DailyLogs.GetAll(song.ID)
+	if err != nil {
+		return "", err
+	}
+	stageLogs, err := d.StageLogs.GetAll(song.ID)
+	if err != nil {
+		return "", err
+	}
+	return coachHistory(song, dailyLogs, stageLogs, d.Settings.Get().StageNames, time.Now()), nil
+}
+
+// coachHistory describes the song's practice history as plain text for the prompt.
+func coachHistory(song *models.Song, dailyLogs []models.DailyLog, stageLogs []models.StageLogEntry, stageNames []string, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Song: %q", song.Title)
+	if song.Artist != "" {
+		fmt.Fprintf(&b, " by %q", song.Artist)
+	}
+	b.WriteString("\n")
+	if song.Tempo != nil && *song.Tempo > 0 {
+		fmt.Fprintf(&b, "Target tempo: %g BPM\n", *song.Tempo)
+	}
+	if labels := song.MusicLabels(); len(labels) > 0 {
+		fmt.Fprintf(&b, "Details: %s\n", strings.Join(labels, ", "))
+	}
+	fmt.Fprintf(&b, "Today: %s\n\n", now.Format("2006-01-02"))
+
+	b.WriteString("Stages:")
+	for i, name := range stageNames {
+		fmt.Fprintf(&b, " %d=%s", i+1, name)
+	}
+	b.WriteString("\n\n")
+
+	sections := map[string]string{}
+	for _, sec := range song.Structure {
+		sections[sec.ID] = sec.Type
+	}
+	names := map[string]string{}
+	for _, ex := range song.Exercises {
+		names[ex.ID] = ex.Name
+	}
+
+	// Stage changes per exercise, oldest first
+	changes := map[string][]models.StageLogEntry{}
+	for _, e := range stageLogs {
+		changes[e.ExerciseID] = append(changes[e.ExerciseID], e)
+	}
+	for _, c := range changes {
+		sort.Slice(c, func(i, j int) bool { return c[i].Timestamp < c[j].Timestamp })
+	}
+
+	// Recent practice per exercise and per day
+	since := now.AddDate(0, 0, -coachHistoryDays).Format("2006-01-02")
+	week := now.AddDate(0, 0, -7).Format("2006-01-02")
+	recent := map[string]int{}
+	lastWeek := map[string]int{}
+	var days []models.DailyLog
+	for _, dl := range dailyLogs {
+		if dl.Date < since {
+			continue
+		}
+		days = append(days, dl)
+		for _, e := range dl.Entries {
+			recent[e.ExerciseID] += e.Seconds
+			if dl.Date >= week {
+				lastWeek[e.ExerciseID] += e.Seconds
+			}
+		}
+	}
+
+	b.WriteString("Exercises:\n")
+	for _, ex := range song.Exercises {
+		if ex.IsTransition && !ex.IsTracked {
+			continue
+		}
+		name := ex.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		fmt.Fprintf(&b, "- [%s] %s", ex.ID, name)
+		if ex.IsTransition {
+			fmt.Fprintf(&b, " (transition from %q to %q)", names[ex.TransitionBetween[0]], names[ex.TransitionBetween[1]])
+		} else if sec := sections[ex.SectionID]; sec != "" {
+			fmt.Fprintf(&b, " (%s)", sec)
+		}
+		b.WriteString("\n")
+
+		stageSince := ex.CreatedAt
+		if c := changes[ex.ID]; len(c) > 0 && c[len(c)-1].Stage == ex.Stage {
+			stageSince = c[len(c)-1].Timestamp
+		}
+		fmt.Fprintf(&b, "    difficulty %d, stage %d", ex.Difficulty, ex.Stage)
+		if t, err := time.Parse(time.RFC3339, stageSince); err == nil {
+			fmt.Fprintf(&b, " for %d days", int(now.Sub(t).Hours()/24))
+		}
+		b.WriteString("\n")
+
+		fmt.Fprintf(&b, "    total %s over %d reps; last %d days %s; last 7 days %s",
+			coachMinutes(ex.TotalPracticedSeconds), ex.TotalReps, coachHistoryDays,
+			coachMinutes(recent[ex.ID]), coachMinutes(lastWeek[ex.ID]))
+		if ex.LastPracticedAt != nil {
+			fmt.Fprintf(&b, "; last practiced %s", (*ex.LastPracticedAt)[:min(10, len(*ex.LastPracticedAt))])
+		} else {
+			b.WriteString("; never practiced")
+		}
+		b.WriteString("\n")
+
+		if c := changes[ex.ID]; len(c) > 0 {
+			b.WriteString("    stage history:")
+			for _, e := range c {
+				fmt.Fprintf(&b, " %d (%s)", e.Stage, e.Timestamp[:min(10, len(e.Timestamp))])
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\nPractice per day (last %d days):\n", coachHistoryDays)
+	if len(days) == 0 {
+		b.WriteString("(none)\n")
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	for _, dl := range days {
+		total := 0
+		for _, e := range dl.Entries {
+			total += e.Seconds
+		}
+		fmt.Fprintf(&b, "- %s: %s across %d exercise(s)\n", dl.Date, coachMinutes(total), len(dl.Entries))
+	}
+
+	return b.String()
+}
+
+func coachMinutes(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm", seconds/60)
+}
+
+// callCoachAI asks the model for practice suggestions.
+func (d *Deps) callCoachAI(ctx context.Context, song *models.Song, history string) (*models.CoachReport, error) {
+	system, user, err := d.renderPrompts("coach", coachPromptData{History: history})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ai.ChatJSON(ctx, d.AI, ai.ChatRequest{
+		Endpoint:    "coach",
+		MaxTokens:   1500,
+		Temperature: 0.4,
+		Schema:      &ai.Schema{Name: "practice_advice", Schema: json.RawMessage(coachSchema)},
+		Messages: []ai.Message{
+			ai.System(system),
+			ai.User(ai.Text(user)),
+		},
+	}, func(res *coachResult) error {
+		return validateCoach(res, song)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CoachReport{
+		ID:          generateID()[:16],
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Model:       d.AI.Model(),
+		Summary:     resp.Summary,
+		Suggestions: []models.CoachSuggestion{},
+	}
+	for _, sg := range resp.Suggestions {
+		s := models.CoachSuggestion{Title: sg.Title, Detail: sg.Detail, Priority: sg.Priority}
+		if sg.ExerciseID != nil {
+			s.ExerciseID = *sg.ExerciseID
+		}
+		report.Suggestions = append(report.Suggestions, s)
+	}
+	return report, nil
+}
+
+// coachResult is the model's answer before it becomes a CoachReport.
+type coachResult struct {
+	Summary     string `json:"summary"`
+	Suggestions []struct {
+		ExerciseID *string `json:"exerciseId"`
+		Title      string  `json:"title"`
+		Detail     string  `json:"detail"`
+		Priority   string  `json:"priority"`
+	} `json:"suggestions"`
+}
+
+const coachSchema = `{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string"},
+    "suggestions": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "exerciseId": {"type": ["string", "null"]},
+          "title": {"type": "string"},
+          "detail": {"type": "string"},
+          "priority": {"type": "string", "enum": ["high", "medium", "low"]}
+        },
+        "required": ["exerciseId", "title", "detail", "priority"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["summary", "suggestions"],
+  "additionalProperties": false
+}`
+
+// validateCoach requires a summary and a valid priority on every suggestion.
+// References to exercises the song doesn't have are dropped, as are empty
+// suggestions; at most six are kept.
+func validateCoach(res *coachResult, song *models.Song) error {
+	res.Summary = strings.TrimSpace(res.Summary)
+	if res.Summary == "" {
+		return fmt.Errorf("summary is empty")
+	}
+
+	exists := map[string]bool{}
+	for _, ex := range song.Exercises {
+		exists[ex.ID] = true
+	}
+
+	kept := res.Suggestions[:0]
+	for i, sg := range res.Suggestions {
+		if err := checkLevel(sg.Priority); err != nil {
+			return fmt.Errorf("suggestion %d: priority %w", i+1, err)
+		}
+		sg.Title = strings.TrimSpace(sg.Title)
+		sg.Detail = strings.TrimSpace(sg.Detail)
+		if sg.Title == "" && sg.Detail == "" {
+			continue
+		}
+		if sg.ExerciseID != nil && !exists[*sg.ExerciseID] {
+			sg.ExerciseID = nil
+		}
+		kept = append(kept, sg)
+		if len(kept) == 6 {
+			break
+		}
+	}
+	res.Suggestions = kept
+	return nil
+}
@@ -15,26 +15,45 @@ import (
 // cropAndSave reads a source page image, crops it using normalized rect
 // coordinates (0-1), and saves the result as a PNG file at outputPath.
 func cropAndSave(pagePath string, rect models.Rect, outputPath string) error {
-	f, err := os.Open(pagePath)
+	src, err := decodeImageFile(pagePath)
 	if err != nil {
-		return fmt.Errorf("open page: %w", err)
+		return err
+	}
+
+	cropped, err := cropImage(src, rect)
+	if err != nil {
+		return err
+	}
+
+	return writePNG(outputPath, cropped)
+}
+
+// decodeImageFile opens and decodes a PNG or JPEG image based on its extension.
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open page: %w", err)
 	}
 	defer f.Close()
 
 	var src image.Image
-	ext := strings.ToLower(filepath.Ext(pagePath))
+	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".png":
 		src, err = png.Decode(f)
 	case ".jpg", ".jpeg":
 		src, err = jpeg.Decode(f)
 	default:
-		return fmt.Errorf("unsupported image format: %s", ext)
+		return nil, fmt.Errorf("unsupported image format: %s", ext)
 	}
 	if err != nil {
-		return fmt.Errorf("decode page: %w", err)
+		return nil, fmt.Errorf("decode page: %w", err)
 	}
+	return src, nil
+}
 
+// cropImage returns the region of src described by a normalized rect (0-1).
+func cropImage(src image.Image, rect models.Rect) (image.Image, error) {
 	bounds := src.Bounds()
 	imgW := bounds.Dx()
 	imgH := bounds.Dy()
@@ -59,10 +78,10 @@ func cropAndSave(pagePath string, rect models.Rect, outputPath string) error {
 		h = imgH - y
 	}
 	if w <= 0 || h <= 0 {
-		return fmt.Errorf("invalid crop dimensions: %dx%d", w, h)
+		return nil, fmt.Errorf("invalid crop dimensions: %dx%d", w, h)
 	}
 
-	cropRect := image.Rect(x, y, x+w, y+h)
+	cropRect := image.Rect(x, y, x+w, y+h).Add(bounds.Min)
 
 	// SubImage avoids copying pixels — just creates a view into the original
 	type subImager interface {
@@ -70,11 +89,13 @@ func cropAndSave(pagePath string, rect models.Rect, outputPath string) error {
 	}
 	sub, ok := src.(subImager)
 	if !ok {
-		return fmt.Errorf("source image does not support SubImage")
+		return nil, fmt.Errorf("source image does not support SubImage")
 	}
-	cropped := sub.SubImage(cropRect)
+	return sub.SubImage(cropRect), nil
+}
 
-	// Write PNG
+// writePNG encodes img as a PNG file at outputPath, creating parent directories.
+func writePNG(outputPath string, img image.Image) error {
 	os.MkdirAll(filepath.Dir(outputPath), 0o755)
 	out, err := os.Create(outputPath)
 	if err != nil {
@@ -82,7 +103,7 @@ func cropAndSave(pagePath string, rect models.Rect, outputPath string) error {
 	}
 	defer out.Close()
 
-	if err := png.Encode(out, cropped); err != nil {
+	if err := png.Encode(out, img); err != nil {
 		return fmt.Errorf("encode png: %w", err)
 	}
 
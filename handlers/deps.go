@@ -1,18 +1,46 @@
 package handlers
 
 import (
+	"io/fs"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/ai"
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/prompts"
 	"github.com/LianHaeming/avoidnt/storage"
 	"github.com/LianHaeming/avoidnt/tmpl"
 )
 
 // Deps holds all handler dependencies.
+//
+// The library stores (Songs, Settings, DailyLogs, StageLogs, Coach, SyncOps)
+// belong to one user: they are nil on the shared Deps and filled in by Scoped.
 type Deps struct {
-	Songs     *storage.SongStore
-	Settings  *storage.SettingsStore
-	Jobs      *storage.JobStore
-	DailyLogs *storage.DailyLogStore
-	StageLogs *storage.StageLogStore
-	Templates *tmpl.Templates
-	OpenAIKey string
-	PdfOutput string
+	User        *models.User // signed-in user, set by Scoped
+	Songs       *storage.SongStore
+	Settings    *storage.SettingsStore
+	DailyLogs   *storage.DailyLogStore
+	StageLogs   *storage.StageLogStore
+	Coach       *storage.CoachStore
+	SyncOps     *storage.SyncOpStore
+	Jobs        *storage.JobStore
+	Templates   *tmpl.Templates
+	Static      fs.FS       // static/ assets
+	AI          ai.Provider // nil when no AI provider is configured
+	AICache     *storage.AICacheStore
+	AIUsage     *AIUsageMeter
+	Prompts     *prompts.Store
+	OCR         *OCR // nil when tesseract is unavailable or disabled
+	PdfOutput   string
+	Previews    PreviewOptions
+	PreviewJobs *PreviewJobs
+	Events      *EventBus
+
+	Users             *storage.UserStore
+	Sessions          *storage.SessionStore
+	Tokens            *storage.TokenStore
+	Libraries         *storage.Libraries
+	SessionTTL        time.Duration
+	AllowRegistration bool   // when false, only the first account can be created
+	ShareKey          []byte // signs public share links
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types published on the EventBus.
+const (
+	EventSong        = "song"         // song saved or its settings changed; Data is nil
+	EventSongDeleted = "song-deleted" // Data is nil
+	EventExercise    = "exercise"     // Data is the updated models.Exercise
+	EventDailyLog    = "daily-log"    // Data is the PatchDailyLogRequest that was applied
+	EventStageLog    = "stage-log"    // Data is the appended models.StageLogEntry
+)
+
+// eventBuffer is how many events a slow subscriber may fall behind before
+// further events to it are dropped.
+const eventBuffer = 32
+
+// sseHeartbeat keeps idle event streams from being closed by proxies.
+const sseHeartbeat = 25 * time.Second
+
+// Event is one change to a user's library.
+type Event struct {
+	Type     string `json:"type"`
+	SongID   string `json:"songId"`
+	Revision int    `json:"revision,omitempty"` // song revision after the change, if it changed
+	Data     any    `json:"data,omitempty"`
+}
+
+// EventBus fans library changes out to the user's open event streams.
+// It is in-process only: every device of a user must reach the same server.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[string]map[*eventSub]struct{} // by user ID
+
+	done      chan struct{} // closed on shutdown
+	closeOnce sync.Once
+}
+
+type eventSub struct {
+	songID string // "" = every song
+	ch     chan Event
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[string]map[*eventSub]struct{}{}, done: make(chan struct{})}
+}
+
+// Close ends every open event stream, so a graceful shutdown doesn't wait on
+// them. Clients reconnect to the next server.
+func (b *EventBus) Close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// Subscribe returns a channel of the user's events, optionally limited to one
+// song, and a function that ends the subscription.
+func (b *EventBus) Subscribe(userID, songID string) (<-chan Event, func()) {
+	sub := &eventSub{songID: songID, ch: make(chan Event, eventBuffer)}
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[*eventSub]struct{}{}
+	}
+	b.subs[userID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		delete(b.subs[userID], sub)
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers an event to the user's subscribers without blocking.
+// Subscribers whose buffer is full miss the event.
+func (b *EventBus) Publish(userID string, ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs[userID] {
+		if sub.songID != "" && sub.songID != ev.SongID {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// publish sends an event for the signed-in user's library.
+func (d *Deps) publish(ev Event) {
+	if d.Events != nil && d.User != nil {
+		d.Events.Publish(d.User.ID, ev)
+	}
+}
+
+// HandleEvents streams the user's library changes as Server-Sent Events.
+// ?songId= limits the stream to one song.
+func (d *Deps) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout
+	rc.SetWriteDeadline(time.Time{})
+
+	events, cancel := d.Events.Subscribe(d.User.ID, r.URL.Query().Get("songId"))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: don't buffer the stream
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-d.Events.done:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev := <-events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/pdfgen"
+)
+
+// exportOptions controls the practice sheet layout.
+type exportOptions struct {
+	Size       pdfgen.Size
+	BelowStage int  // only include exercises with stage < BelowStage (0 = all)
+	Link       bool // print a clickable link back to the song
+	BaseURL    string
+}
+
+const (
+	exportMargin  = 40.0
+	exportImgDPI  = 288.0 // previews are cropped from 288-DPI page renders
+	exportQuality = 85
+)
+
+var (
+	exportGray  = color.RGBA{107, 114, 128, 255}
+	exportBlack = color.RGBA{17, 24, 39, 255}
+	exportBlue  = color.RGBA{37, 99, 235, 255}
+)
+
+// HandleExportSongPDF renders a printable practice sheet for one song.
+// Query params: size=a4|letter, below=N (only stages below N), link=1.
+func (d *Deps) HandleExportSongPDF(w http.ResponseWriter, r *http.Request) {
+	song, err := d.Songs.Get(r.PathValue("songId"))
+	if err != nil || song == nil {
+		http.NotFound(w, r)
+		return
+	}
+	d.writeExportPDF(w, r, []*models.Song{song}, song.Title)
+}
+
+// HandleExportSetlistPDF renders practice sheets for several songs in order,
+// each starting on a new page. Songs are given as ?songs=id1,id2,...
+func (d *Deps) HandleExportSetlistPDF(w http.ResponseWriter, r *http.Request) {
+	var songs []*models.Song
+	for _, id := range strings.Split(r.URL.Query().Get("songs"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		song, err := d.Songs.Get(id)
+		if err != nil || song == nil {
+			http.Error(w, "Song not found: "+id, http.StatusNotFound)
+			return
+		}
+		songs = append(songs, song)
+	}
+	if len(songs) == 0 {
+		http.Error(w, "No songs selected", http.StatusBadRequest)
+		return
+	}
+	d.writeExportPDF(w, r, songs, "setlist")
+}
+
+func (d *Deps) writeExportPDF(w http.ResponseWriter, r *http.Request, songs []*models.Song, name string) {
+	opts, err := parseExportOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc := pdfgen.New(opts.Size)
+	stageNames := d.Settings.Get().StageNames
+	for _, song := range songs {
+		d.layoutSongSheet(r.Context(), doc, song, stageNames, opts)
+	}
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		http.Error(w, "Failed to build PDF", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", slugify(name)+".pdf"))
+	w.Write(buf.Bytes())
+}
+
+func parseExportOptions(r *http.Request) (exportOptions, error) {
+	q := r.URL.Query()
+	opts := exportOptions{Size: pdfgen.A4}
+
+	switch strings.ToLower(q.Get("size")) {
+	case "", "a4":
+	case "letter":
+		opts.Size = pdfgen.Letter
+	default:
+		return opts, fmt.Errorf("size must be 'a4' or 'letter'")
+	}
+
+	if v := q.Get("below"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > 6 {
+			return opts, fmt.Errorf("below must be 2-6")
+		}
+		opts.BelowStage = n
+	}
+
+	if q.Get("link") == "1" || q.Get("link") == "true" {
+		opts.Link = true
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		opts.BaseURL = scheme + "://" + r.Host
+	}
+
+	return opts, nil
+}
+
+// sheetWriter tracks the cursor while flowing content down the pages.
+type sheetWriter struct {
+	doc  *pdfgen.Document
+	page *pdfgen.Page
+	y    float64
+}
+
+func (s *sheetWriter) contentWidth() float64 {
+	return s.doc.Size().W - 2*exportMargin
+}
+
+// ensure starts a new page unless h points of vertical space remain.
+func (s *sheetWriter) ensure(h float64) {
+	if s.page == nil || s.y+h > s.doc.Size().H-exportMargin {
+		s.page = s.doc.AddPage()
+		s.y = exportMargin
+	}
+}
+
+func (d *Deps) layoutSongSheet(ctx context.Context, doc *pdfgen.Document, song *models.Song, stageNames []string, opts exportOptions) {
+	s := &sheetWriter{doc: doc}
+	s.ensure(0)
+	width := s.contentWidth()
+
+	// Header
+	s.y += 22
+	s.page.TextColor(exportBlack)
+	s.page.Text(exportMargin, s.y, pdfgen.HelveticaBold, 20, pdfgen.Truncate(song.Title, pdfgen.HelveticaBold, 20, width))
+
+	var meta []string
+	if song.Artist != "" {
+		meta = append(meta, song.Artist)
+	}
+	if song.Tempo != nil && *song.Tempo > 0 {
+		meta = append(meta, fmt.Sprintf("Target tempo: %g BPM", *song.Tempo))
+	}
+	meta = append(meta, song.MusicLabels()...)
+	meta = append(meta, time.Now().Format("2 Jan 2006"))
+	s.y += 18
+	s.page.TextColor(exportGray)
+	s.page.Text(exportMargin, s.y, pdfgen.Helvetica, 11, pdfgen.Truncate(strings.Join(meta, "  ·  "), pdfgen.Helvetica, 11, width))
+
+	if opts.Link {
+		url := opts.BaseURL + "/songs/" + song.ID
+		s.y += 16
+		s.page.TextColor(exportBlue)
+		s.page.Text(exportMargin, s.y, pdfgen.Helvetica, 10, url)
+		s.page.Link(exportMargin, s.y-10, pdfgen.TextWidth(url, pdfgen.Helvetica, 10), 13, url)
+	}
+	s.y += 14
+
+	for _, group := range exportGroups(song) {
+		var exs []models.Exercise
+		for _, ex := range group.Exercises {
+			if opts.BelowStage == 0 || ex.Stage < opts.BelowStage {
+				exs = append(exs, ex)
+			}
+		}
+		if len(exs) == 0 {
+			continue
+		}
+
+		if group.Label != "" {
+			s.ensure(60)
+			s.y += 22
+			s.page.TextColor(exportBlack)
+			s.page.Text(exportMargin, s.y, pdfgen.HelveticaBold, 14, group.Label)
+			s.y += 6
+		}
+
+		for i := range exs {
+			d.layoutExercise(ctx, s, song, &exs[i], stageNames)
+		}
+	}
+}
+
+func (d *Deps) layoutExercise(ctx context.Context, s *sheetWriter, song *models.Song, ex *models.Exercise, stageNames []string) {
+	width := s.contentWidth()
+
+	// Decode the stitched preview first so the heading can stay on the
+	// same page as its image.
+	var img *pdfgen.Image
+	var imgW, imgH float64
+	if len(ex.Crops) > 0 {
+		var err error
+		img, err = d.exportImage(s.doc, song, ex)
+		if err != nil {
+			slog.WarnContext(ctx, "Export: skipping exercise image", "song", song.ID, "exercise", ex.ID, "err", err)
+		}
+		if img != nil {
+			imgW = float64(img.Width) * 72 / exportImgDPI
+			imgH = float64(img.Height) * 72 / exportImgDPI
+			maxH := s.doc.Size().H - 2*exportMargin - 40
+			if imgW > width {
+				imgH *= width / imgW
+				imgW = width
+			}
+			if imgH > maxH {
+				imgW *= maxH / imgH
+				imgH = maxH
+			}
+		}
+	}
+
+	s.ensure(34 + imgH)
+	s.y += 20
+
+	stage := ex.Stage
+	stageLabel := fmt.Sprintf("Stage %d", stage)
+	if stage >= 1 && stage <= len(stageNames) {
+		stageLabel += " · " + stageNames[stage-1]
+	}
+	labelW := pdfgen.TextWidth(stageLabel, pdfgen.Helvetica, 9)
+
+	name := ex.Name
+	if name == "" {
+		name = "Untitled exercise"
+	}
+	s.page.TextColor(exportBlack)
+	s.page.Text(exportMargin, s.y, pdfgen.HelveticaBold, 11,
+		pdfgen.Truncate(name, pdfgen.HelveticaBold, 11, width-labelW-24))
+
+	// Stage chip, right-aligned
+	chipX := exportMargin + width - labelW - 14
+	s.page.FillRect(chipX, s.y-9, 8, 8, parseHexColor(ptr(models.StageColor(stage))))
+	s.page.TextColor(exportGray)
+	s.page.Text(chipX+12, s.y-1, pdfgen.Helvetica, 9, stageLabel)
+
+	s.y += 8
+	if img != nil {
+		s.page.DrawImage(img, exportMargin, s.y, imgW, imgH)
+		s.y += imgH
+	}
+	s.y += 6
+}
+
+// exportImage embeds the exercise's stitched preview in the document.
+func (d *Deps) exportImage(doc *pdfgen.Document, song *models.Song, ex *models.Exercise) (*pdfgen.Image, error) {
+	path, err := d.exerciseImage(song, ex, 0)
+	if err != nil {
+		return nil, err
+	}
+	src, err := decodeImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return doc.AddImage(src, exportQuality)
+}
+
+// exportGroups is buildSectionGroups plus a trailing group for exercises
+// whose section no longer exists, so nothing is silently left off the sheet.
+func exportGroups(song *models.Song) []SectionGroup {
+	groups := buildSectionGroups(song)
+	if len(song.Structure) == 0 {
+		return groups
+	}
+
+	placed := map[string]bool{}
+	for _, g := range groups {
+		for _, ex := range g.Exercises {
+			placed[ex.ID] = true
+		}
+	}
+	var rest []models.Exercise
+	for _, ex := range song.Exercises {
+		if !placed[ex.ID] {
+			rest = append(rest, ex)
+		}
+	}
+	if len(rest) > 0 {
+		groups = append(groups, SectionGroup{Label: "Other", Exercises: rest})
+	}
+	return groups
+}
+
+// slugify turns a title into a safe file name.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	out := strings.TrimSuffix(b.String(), "-")
+	if out == "" {
+		return "practice-sheet"
+	}
+	return out
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OCR extracts song metadata from page images with a local tesseract binary,
+// for when no AI provider is configured.
+type OCR struct {
+	Path string // tesseract binary
+	Lang string // tesseract language, e.g. "eng"
+}
+
+// NewOCR resolves the tesseract binary. mode is "auto" (use it if installed),
+// "on" or "off". It returns nil when OCR is disabled or unavailable.
+func NewOCR(mode, lang string) *OCR {
+	if mode == "off" {
+		return nil
+	}
+	path, err := exec.LookPath("tesseract")
+	if err != nil {
+		if mode == "on" {
+			log.Printf("Warning: OCR_ENGINE=on but tesseract was not found on PATH; offline analysis disabled")
+		}
+		return nil
+	}
+	if lang == "" {
+		lang = "eng"
+	}
+	return &OCR{Path: path, Lang: lang}
+}
+
+// ocrWord is one recognized word with its bounding box in pixels.
+type ocrWord struct {
+	block, par, line      int
+	left, top, width, hgt int
+	conf                  float64
+	text                  string
+}
+
+// ocrLine is a line of text on a page, with its position relative to the page.
+type ocrLine struct {
+	page   int
+	text   string
+	top    float64 // 0 = top edge, 1 = bottom edge
+	left   float64 // 0 = left edge, 1 = right edge
+	right  float64
+	height float64 // median word height as a fraction of the page height
+}
+
+// Analyze recognizes the text on each page (data URLs) and applies the
+// metadata heuristics.
+func (o *OCR) Analyze(ctx context.Context, pageImages []string) (*AnalyzeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	var lines []ocrLine
+	for i, img := range pageImages {
+		pageLines, err := o.recognize(ctx, i, img)
+		if err != nil {
+			return nil, fmt.Errorf("OCR of page %d failed: %w", i+1, err)
+		}
+		lines = append(lines, pageLines...)
+	}
+
+	res := analyzeOCRLines(lines)
+	validateAnalyze(res)
+	return res, nil
+}
+
+// recognize runs tesseract on one page and groups its words into lines.
+func (o *OCR) recognize(ctx context.Context, page int, dataURL string) ([]ocrLine, error) {
+	b64 := dataURL
+	if i := strings.Index(b64, ","); strings.HasPrefix(b64, "data:") && i >= 0 {
+		b64 = b64[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "ocr-page-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	tmp.Close()
+
+	// tesseract page.png stdout -l eng tsv
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, o.Path, tmp.Name(), "stdout", "-l", o.Lang, "tsv")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTesseractTSV(out, page), nil
+}
+
+// parseTesseractTSV reads tesseract's TSV output into lines. Columns:
+// level page_num block_num par_num line_num word_num left top width height conf text
+func parseTesseractTSV(out []byte, page int) []ocrLine {
+	var words []ocrWord
+	pageW, pageH := 0, 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		cols := strings.Split(scanner.Text(), "\t")
+		if len(cols) < 12 {
+			continue
+		}
+		level, err := strconv.Atoi(cols[0])
+		if err != nil {
+			continue // header row
+		}
+		n := make([]int, 10)
+		for i := 1; i <= 9; i++ {
+			n[i], _ = strconv.Atoi(cols[i])
+		}
+		conf, _ := strconv.ParseFloat(cols[10], 64)
+		switch level {
+		case 1: // page
+			pageW, pageH = n[8], n[9]
+		case 5: // word
+			text := strings.TrimSpace(cols[11])
+			if text == "" || conf < 30 {
+				continue
+			}
+			words = append(words, ocrWord{block: n[2], par: n[3], line: n[4], left: n[6], top: n[7], width: n[8], hgt: n[9], conf: conf, text: text})
+		}
+	}
+	if pageW == 0 || pageH == 0 {
+		return nil
+	}
+
+	type lineKey struct{ block, par, line int }
+	grouped := map[lineKey][]ocrWord{}
+	var order []lineKey
+	for _, w := range words {
+		k := lineKey{w.block, w.par, w.line}
+		if _, ok := grouped[k]; !ok {
+			order = append(order, k)
+		}
+		grouped[k] = append(grouped[k], w)
+	}
+
+	var lines []ocrLine
+	for _, k := range order {
+		ws := grouped[k]
+		texts := make([]string, len(ws))
+		heights := make([]int, len(ws))
+		top, left, right := ws[0].top, ws[0].left, ws[0].left+ws[0].width
+		for i, w := range ws {
+			texts[i] = w.text
+			heights[i] = w.hgt
+			top = min(top, w.top)
+			left = min(left, w.left)
+			right = max(right, w.left+w.width)
+		}
+		sort.Ints(heights)
+		lines = append(lines, ocrLine{
+			page:   page,
+			text:   strings.Join(texts, " "),
+			top:    float64(top) / float64(pageH),
+			left:   float64(left) / float64(pageW),
+			right:  float64(right) / float64(pageW),
+			height: float64(heights[len(heights)/2]) / float64(pageH),
+		})
+	}
+	return lines
+}
+
+var (
+	// "♩ = 120" usually comes out of OCR as "J = 120", "d= 120" or "= 120"
+	ocrTempoPattern   = regexp.MustCompile(`(?i)(?:(?:^|\s)[♩♪jdq]?\s*=\s*|tempo[:\s]*|bpm\s*=?\s*)(\d{2,3})\b|\b(\d{2,3})\s*bpm\b`)
+	ocrCreditPattern  = regexp.MustCompile(`(?i)^(?:(?:words\s+(?:and|&)\s+)?music\s+by|composed\s+by|written\s+by|arranged\s+by|arr\.|by)\s+(.+)$`)
+	ocrSectionPattern = regexp.MustCompile(`(?i)^\[?\s*(intro|verse|pre[- ]?chorus|chorus|bridge|solo|guitar solo|interlude|outro|coda|breakdown|riff|instrumental|refrain|ending|hook)\s*\d*\s*\]?:?$`)
+	ocrCapoPattern    = regexp.MustCompile(`(?i)\bcapo\s*(?:on\s*)?(\d{1,2})`)
+	ocrTuningPattern  = regexp.MustCompile(`(?i)\b(drop\s+[a-g][#b]?|dadgad|open\s+[a-g][#b]?|[a-g][#b]?\s+standard|half[- ]step down)\b`)
+)
+
+// analyzeOCRLines applies layout and text heuristics to recognized lines:
+// the tallest text near the top of page 1 is the title, a "by"/"music by"
+// credit or a right-aligned line under it is the artist, "♩ = N" is the
+// tempo, and short lines naming a song part are sections.
+func analyzeOCRLines(lines []ocrLine) *AnalyzeResponse {
+	res := &AnalyzeResponse{Sections: []string{}}
+
+	var title *ocrLine
+	for i := range lines {
+		l := &lines[i]
+		if l.page != 0 || l.top > 0.3 || !ocrIsWordy(l.text) || ocrTempoPattern.MatchString(l.text) {
+			continue
+		}
+		if title == nil || l.height > title.height {
+			title = l
+		}
+	}
+	if title != nil {
+		res.Title = optString(strings.Trim(title.text, " \"'“”"))
+	}
+
+	for i := range lines {
+		l := &lines[i]
+		if l.page != 0 || l.top > 0.35 || l == title {
+			continue
+		}
+		if m := ocrCreditPattern.FindStringSubmatch(l.text); m != nil {
+			res.Artist = optString(strings.TrimSpace(m[1]))
+			break
+		}
+		// Composers are conventionally printed right-aligned under the title
+		if res.Artist == nil && title != nil && l.top > title.top && l.left > 0.55 && ocrIsWordy(l.text) && !ocrTempoPattern.MatchString(l.text) {
+			res.Artist = optString(l.text)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, l := range lines {
+		if res.Tempo == nil {
+			if m := ocrTempoPattern.FindStringSubmatch(l.text); m != nil {
+				n, _ := strconv.Atoi(m[1] + m[2])
+				res.Tempo = &n
+			}
+		}
+		if res.Capo == nil {
+			if m := ocrCapoPattern.FindStringSubmatch(l.text); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				res.Capo = &n
+			}
+		}
+		if res.Tuning == nil {
+			if m := ocrTuningPattern.FindStringSubmatch(l.text); m != nil {
+				res.Tuning = optString(ocrTuningName(m[1]))
+			}
+		}
+		if m := ocrSectionPattern.FindStringSubmatch(strings.TrimSpace(l.text)); m != nil {
+			name := capitalizeWords(strings.ReplaceAll(strings.ToLower(m[1]), "prechorus", "pre-chorus"))
+			if !seen[name] {
+				seen[name] = true
+				res.Sections = append(res.Sections, name)
+			}
+		}
+	}
+	return res
+}
+
+// ocrIsWordy reports whether s looks like real text rather than OCR noise
+// from staff lines and notes: mostly letters, at least three of them.
+func ocrIsWordy(s string) bool {
+	letters, other := 0, 0
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r > 127 && r != '♩' && r != '♪':
+			letters++
+		case r == ' ' || r == '\'' || r == '-' || r == ',' || r == '.':
+		default:
+			other++
+		}
+	}
+	return letters >= 3 && letters >= 3*other
+}
+
+// ocrTuningName formats a matched tuning: "drop d" -> "Drop D",
+// "dadgad" -> "DADGAD", "eb standard" -> "Eb Standard".
+func ocrTuningName(s string) string {
+	s = strings.ToLower(s)
+	if s == "dadgad" {
+		return "DADGAD"
+	}
+	return capitalizeWords(s)
+}
+
+// capitalizeWords upper-cases the first letter of each word.
+func capitalizeWords(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -185,13 +185,27 @@ func buildLibrarySections(summaries []models.SongSummary) (continuePracticing, n
 
 // SettingsPageData is the template data for the settings page.
 type SettingsPageData struct {
-	Settings models.UserSettings
+	Settings     models.UserSettings
+	Username     string
+	Teachers     []AccountInfo
+	StudentCount int
+	Tokens       []APITokenInfo
 }
 
 // HandleSettingsPage renders the settings page.
 func (d *Deps) HandleSettingsPage(w http.ResponseWriter, r *http.Request) {
 	settings := d.Settings.Get()
-	data := SettingsPageData{Settings: settings}
+	students, _ := d.Users.StudentsOf(d.User.ID)
+	tokens, _ := d.Tokens.List(d.User.ID)
+	data := SettingsPageData{
+		Settings:     settings,
+		Username:     d.User.Username,
+		Teachers:     d.teachersOf(d.User),
+		StudentCount: len(students),
+	}
+	for _, t := range tokens {
+		data.Tokens = append(data.Tokens, tokenInfo(t))
+	}
 	d.render(w, "settings.html", data)
 }
 
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// maxIdempotencyKey caps the length of an Idempotency-Key header.
+const maxIdempotencyKey = 200
+
+// isoMillis formats times like JavaScript's Date.toISOString, which the
+// browser uses for lastPracticedAt, so the strings keep sorting correctly.
+const isoMillis = "2006-01-02T15:04:05.000Z07:00"
+
+// recordPractice adds practice time and reps to an exercise's totals and to
+// the daily log for the day it happened. The log is written while the song's
+// lock is held and taken back if the song can't be saved, so the totals and
+// the log never drift apart.
+func (d *Deps) recordPractice(songID, exerciseID string, seconds, reps int, at time.Time) (*models.Song, PatchDailyLogRequest, error) {
+	logReq := PatchDailyLogRequest{
+		Date:       at.UTC().Format("2006-01-02"),
+		ExerciseID: exerciseID,
+		Seconds:    seconds,
+		Reps:       reps,
+	}
+
+	var logged bool
+	song, err := d.Songs.Update(songID, func(song *models.Song) error {
+		ex := song.FindExercise(exerciseID)
+		if ex == nil {
+			return &songWriteError{"Exercise not found", http.StatusNotFound}
+		}
+		ex.TotalPracticedSeconds += seconds
+		ex.TotalReps += reps
+		ts := at.UTC().Format(isoMillis)
+		if ex.LastPracticedAt == nil || *ex.LastPracticedAt < ts {
+			ex.LastPracticedAt = &ts
+		}
+
+		if err := d.DailyLogs.Upsert(songID, logReq.Date, exerciseID, seconds, reps); err != nil {
+			return err
+		}
+		logged = true
+		return nil
+	})
+	if err != nil && logged {
+		d.DailyLogs.Upsert(songID, logReq.Date, exerciseID, -seconds, -reps)
+	}
+	return song, logReq, err
+}
+
+// RecordPracticeRequest is the JSON body for POST .../exercises/{exerciseId}/practice.
+type RecordPracticeRequest struct {
+	Seconds int    `json:"seconds"` // seconds practiced since the last report
+	Reps    int    `json:"reps"`    // reps done since the last report
+	At      string `json:"at"`      // when it happened, RFC 3339; default now
+}
+
+// HandleRecordPractice adds a practice delta to an exercise and its daily
+// log. The Idempotency-Key header is required: a retry with the same key is
+// answered with the current totals instead of being counted again.
+func (d *Deps) HandleRecordPractice(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" || len(key) > maxIdempotencyKey {
+		jsonError(w, "Idempotency-Key header required", http.StatusBadRequest)
+		return
+	}
+
+	var req RecordPracticeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if req.At == "" {
+		req.At = now.UTC().Format(time.RFC3339)
+	}
+
+	res := d.applySyncOp(r.Context(), SyncOp{
+		ID:         key,
+		Type:       SyncPractice,
+		SongID:     r.PathValue("songId"),
+		ExerciseID: r.PathValue("exerciseId"),
+		At:         req.At,
+		Seconds:    req.Seconds,
+		Reps:       req.Reps,
+	}, now)
+
+	switch res.Status {
+	case SyncApplied:
+		w.Header().Set("ETag", models.Song{Revision: res.Revision}.ETag())
+		jsonOK(w, map[string]any{"success": true, "revision": res.Revision, "exercise": res.Exercise, "replayed": false})
+	case SyncDuplicate:
+		song, err := d.Songs.Get(r.PathValue("songId"))
+		if err != nil || song == nil {
+			jsonError(w, "Song not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", song.ETag())
+		jsonOK(w, map[string]any{"success": true, "revision": song.Revision, "exercise": song.FindExercise(r.PathValue("exerciseId")), "replayed": true})
+	default:
+		jsonError(w, res.Error, res.code)
+	}
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// maxFinishedPreviewJobs is how many completed jobs are kept for status queries.
+const maxFinishedPreviewJobs = 50
+
+// PreviewJobs regenerates crop previews in the background. Each source page
+// is decoded once and reused for every crop on it, and at most `concurrency`
+// pages are processed at a time across all jobs.
+type PreviewJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*PreviewJob
+	sem  chan struct{}
+	wg   sync.WaitGroup
+}
+
+// PreviewJob reports the progress of one regeneration run.
+type PreviewJob struct {
+	ID          string            `json:"id"`
+	UserID      string            `json:"-"` // who started it; only they can see it
+	SongIDs     []string          `json:"songIds"`
+	Status      string            `json:"status"` // "running", "done"
+	Total       int               `json:"total"`  // crops to regenerate
+	Completed   int               `json:"completed"`
+	Regenerated int               `json:"regenerated"`
+	Failures    []PreviewFailure  `json:"failures"`
+	Previews    map[string]string `json:"previews"` // cropId -> new preview hash
+	StartedAt   string            `json:"startedAt"`
+	FinishedAt  *string           `json:"finishedAt"`
+}
+
+// PreviewFailure describes a crop that could not be regenerated.
+type PreviewFailure struct {
+	SongID     string `json:"songId"`
+	ExerciseID string `json:"exerciseId"`
+	CropID     string `json:"cropId"`
+	Error      string `json:"error"`
+}
+
+// NewPreviewJobs creates a job runner processing up to concurrency pages at once.
+func NewPreviewJobs(concurrency int) *PreviewJobs {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &PreviewJobs{
+		jobs: map[string]*PreviewJob{},
+		sem:  make(chan struct{}, concurrency),
+	}
+}
+
+// Get returns a snapshot of a job started by userID, or nil if unknown.
+func (p *PreviewJobs) Get(id, userID string) *PreviewJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job, ok := p.jobs[id]
+	if !ok || job.UserID != userID {
+		return nil
+	}
+	snapshot := *job
+	snapshot.Failures = append([]PreviewFailure{}, job.Failures...)
+	snapshot.Previews = make(map[string]string, len(job.Previews))
+	for k, v := range job.Previews {
+		snapshot.Previews[k] = v
+	}
+	return &snapshot
+}
+
+// Wait blocks until all running jobs have finished.
+func (p *PreviewJobs) Wait() {
+	p.wg.Wait()
+}
+
+// HandleRegeneratePreviews re-crops all exercise previews of a song from its
+// source page images at full resolution, in the background. Poll the
+// returned job via GET /api/preview-jobs/{jobId}.
+func (d *Deps) HandleRegeneratePreviews(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+
+	song, err := d.Songs.Get(songID)
+	if err != nil || song == nil {
+		jsonError(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	if song.JobID == "" {
+		jsonError(w, "Song has no source pages", http.StatusBadRequest)
+		return
+	}
+
+	job := d.startPreviewJob([]models.Song{*song})
+	jsonOK(w, map[string]any{"success": true, "jobId": job.ID})
+}
+
+// HandleRegenerateAllPreviews regenerates previews for every song in the library.
+func (d *Deps) HandleRegenerateAllPreviews(w http.ResponseWriter, r *http.Request) {
+	songs, err := d.Songs.ListAll()
+	if err != nil {
+		jsonError(w, "Failed to load songs", http.StatusInternalServerError)
+		return
+	}
+
+	var withPages []models.Song
+	for _, s := range songs {
+		if s.JobID != "" {
+			withPages = append(withPages, s)
+		}
+	}
+
+	job := d.startPreviewJob(withPages)
+	jsonOK(w, map[string]any{"success": true, "jobId": job.ID})
+}
+
+// HandleGetPreviewJob reports the progress of a regeneration job.
+func (d *Deps) HandleGetPreviewJob(w http.ResponseWriter, r *http.Request) {
+	job := d.PreviewJobs.Get(r.PathValue("jobId"), d.User.ID)
+	if job == nil {
+		jsonError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, job)
+}
+
+// pageTask is every crop that is cut from one source page.
+type pageTask struct {
+	song    *models.Song
+	pageNum int
+	crops   []cropRef
+}
+
+type cropRef struct {
+	exerciseID string
+	crop       models.Crop
+}
+
+func (d *Deps) startPreviewJob(songs []models.Song) *PreviewJob {
+	p := d.PreviewJobs
+	job := &PreviewJob{
+		ID:        generateID(),
+		UserID:    d.User.ID,
+		Status:    "running",
+		Failures:  []PreviewFailure{},
+		Previews:  map[string]string{},
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var tasks []pageTask
+	for i := range songs {
+		song := &songs[i]
+		job.SongIDs = append(job.SongIDs, song.ID)
+
+		byPage := map[int]*pageTask{}
+		for _, ex := range song.Exercises {
+			for _, crop := range ex.Crops {
+				pageNum := crop.PageIndex + 1 // pageIndex is 0-based
+				t, ok := byPage[pageNum]
+				if !ok {
+					t = &pageTask{song: song, pageNum: pageNum}
+					byPage[pageNum] = t
+				}
+				t.crops = append(t.crops, cropRef{exerciseID: ex.ID, crop: crop})
+				job.Total++
+			}
+		}
+		pages := make([]int, 0, len(byPage))
+		for n := range byPage {
+			pages = append(pages, n)
+		}
+		sort.Ints(pages)
+		for _, n := range pages {
+			tasks = append(tasks, *byPage[n])
+		}
+	}
+
+	p.mu.Lock()
+	p.jobs[job.ID] = job
+	p.pruneLocked()
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		d.runPreviewJob(job, songs, tasks)
+	}()
+
+	return job
+}
+
+func (d *Deps) runPreviewJob(job *PreviewJob, songs []models.Song, tasks []pageTask) {
+	p := d.PreviewJobs
+
+	var wg sync.WaitGroup
+	for i := range tasks {
+		wg.Add(1)
+		p.sem <- struct{}{}
+		go func(t *pageTask) {
+			defer wg.Done()
+			defer func() { <-p.sem }()
+			d.renderPage(job, t)
+		}(&tasks[i])
+	}
+	wg.Wait()
+
+	// Persist new preview hashes on a fresh copy of each song so edits made
+	// while the job ran are kept.
+	p.mu.Lock()
+	hashes := make(map[string]string, len(job.Previews))
+	for k, v := range job.Previews {
+		hashes[k] = v
+	}
+	p.mu.Unlock()
+
+	for _, s := range songs {
+		d.saveRegeneratedHashes(job.ID, s.ID, hashes)
+	}
+
+	p.mu.Lock()
+	now := time.Now().UTC().Format(time.RFC3339)
+	job.Status = "done"
+	job.FinishedAt = &now
+	p.mu.Unlock()
+	slog.Info("Preview job finished", "job", job.ID, "regenerated", job.Regenerated, "total", job.Total, "failed", len(job.Failures))
+}
+
+// renderPage decodes one source page and re-crops every preview taken from it.
+func (d *Deps) renderPage(job *PreviewJob, t *pageTask) {
+	song := t.song
+	if current, err := d.Songs.Get(song.ID); err == nil && current == nil {
+		for range t.crops {
+			d.PreviewJobs.recordSkipped(job)
+		}
+		return
+	}
+
+	var page image.Image
+	pagePath, err := d.Jobs.GetPagePath(song.JobID, t.pageNum)
+	if err == nil {
+		page, err = decodeImageFile(pagePath)
+	}
+	if err != nil {
+		for _, c := range t.crops {
+			d.PreviewJobs.recordResult(job, song.ID, c, "", fmt.Errorf("page %d: %w", t.pageNum, err))
+		}
+		return
+	}
+
+	for _, c := range t.crops {
+		// The job works on a snapshot; ReplacePreview skips crops that were
+		// deleted or moved since it started
+		path := d.Songs.PreviewPath(song.ID, c.crop.ID)
+		cropped, err := cropImage(page, c.crop.Rect)
+		var tmp string
+		if err == nil {
+			if tmp, err = tempPath(path); os.IsNotExist(err) {
+				err = storage.ErrCropNotFound // the song directory is gone
+			}
+		}
+		if err == nil {
+			if err = writePNG(tmp, cropped); err != nil {
+				os.Remove(tmp)
+			}
+		}
+		if err == nil {
+			err = d.Songs.ReplacePreview(song.ID, c.crop, tmp)
+		}
+		if errors.Is(err, storage.ErrCropNotFound) {
+			d.PreviewJobs.recordSkipped(job)
+			continue
+		}
+		if err != nil {
+			d.PreviewJobs.recordResult(job, song.ID, c, "", err)
+			continue
+		}
+
+		d.buildPreviewVariants(job.ID, song.ID, c.crop.ID)
+		d.Songs.RefreshPreviewHash(song.ID, &c.crop)
+		d.PreviewJobs.recordResult(job, song.ID, c, c.crop.PreviewHash, nil)
+	}
+}
+
+// recordSkipped counts a crop whose song or crop was deleted while the job ran.
+func (p *PreviewJobs) recordSkipped(job *PreviewJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job.Completed++
+}
+
+func (p *PreviewJobs) recordResult(job *PreviewJob, songID string, c cropRef, hash string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job.Completed++
+	if err != nil {
+		slog.Error("Failed to regenerate crop", "job", job.ID, "song", songID, "crop", c.crop.ID, "err", err)
+		job.Failures = append(job.Failures, PreviewFailure{
+			SongID:     songID,
+			ExerciseID: c.exerciseID,
+			CropID:     c.crop.ID,
+			Error:      err.Error(),
+		})
+		return
+	}
+	job.Regenerated++
+	job.Previews[c.crop.ID] = hash
+}
+
+func (d *Deps) saveRegeneratedHashes(jobID, songID string, hashes map[string]string) {
+	if err := d.Songs.SetPreviewHashes(songID, hashes); err != nil {
+		slog.Error("Failed to save preview hashes", "job", jobID, "song", songID, "err", err)
+	}
+}
+
+// pruneLocked drops the oldest finished jobs beyond the retention limit.
+func (p *PreviewJobs) pruneLocked() {
+	var finished []*PreviewJob
+	for _, j := range p.jobs {
+		if j.FinishedAt != nil {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinishedPreviewJobs {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool {
+		return *finished[i].FinishedAt < *finished[k].FinishedAt
+	})
+	for _, j := range finished[:len(finished)-maxFinishedPreviewJobs] {
+		delete(p.jobs, j.ID)
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// PreviewOptions configures the resized/compressed preview variants that are
+// produced alongside each PNG master.
+type PreviewOptions struct {
+	Widths      []int  // ascending variant widths in pixels
+	JPEGQuality int    // 1-100
+	CwebpPath   string // path to cwebp; empty disables WebP output
+}
+
+// NewPreviewOptions parses a comma-separated width list and resolves the
+// WebP encoder. webp is "auto" (use cwebp if installed), "on" or "off".
+func NewPreviewOptions(widths string, quality int, webp string) PreviewOptions {
+	opts := PreviewOptions{JPEGQuality: quality}
+	if opts.JPEGQuality < 1 || opts.JPEGQuality > 100 {
+		opts.JPEGQuality = 82
+	}
+
+	for _, part := range strings.Split(widths, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && n > 0 {
+			opts.Widths = append(opts.Widths, n)
+		}
+	}
+	sort.Ints(opts.Widths)
+
+	if webp != "off" {
+		if path, err := exec.LookPath("cwebp"); err == nil {
+			opts.CwebpPath = path
+		} else if webp == "on" {
+			log.Printf("Warning: PREVIEW_WEBP=on but cwebp was not found on PATH; serving JPEG only")
+		}
+	}
+	return opts
+}
+
+// negotiatePreview picks the variant for a request: WebP when the client
+// accepts it and it is enabled, JPEG otherwise. Width 0 means full size.
+// ok is false when the PNG master should be served unchanged.
+func (o PreviewOptions) negotiatePreview(r *http.Request) (width int, ext string, ok bool) {
+	webp := o.CwebpPath != "" && strings.Contains(r.Header.Get("Accept"), "image/webp")
+
+	w, _ := strconv.Atoi(r.URL.Query().Get("w"))
+	if w <= 0 {
+		if webp {
+			return 0, "webp", true
+		}
+		return 0, "", false
+	}
+
+	for _, candidate := range o.Widths {
+		if candidate >= w {
+			width = candidate
+			break
+		}
+	}
+	if webp {
+		return width, "webp", true
+	}
+	return width, "jpg", true
+}
+
+// previewVariant returns the path of a preview variant, building it from the
+// PNG master if it does not exist yet. Variants never upscale: a width at or
+// above the master's width produces a full-size variant.
+func (d *Deps) previewVariant(songID, cropID string, width int, ext string) (string, error) {
+	path := d.Songs.PreviewVariantPath(songID, cropID, width, ext)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	data, err := os.ReadFile(d.Songs.PreviewPath(songID, cropID))
+	if err != nil {
+		return "", err
+	}
+	masterHash := storage.HashBytes(data)
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("decode preview: %w", err)
+	}
+	if width > 0 && width < src.Bounds().Dx() {
+		src = resizeToWidth(src, width)
+	}
+
+	// Write to a temp file and rename so concurrent requests never see partial
+	// output. The rename is skipped if the master was replaced meanwhile.
+	tmp, err := tempPath(path)
+	if err != nil {
+		return "", err
+	}
+	switch ext {
+	case "jpg":
+		err = writeJPEG(tmp, src, d.Previews.JPEGQuality)
+	case "webp":
+		err = writeWebP(d.Previews.CwebpPath, tmp, src, d.Previews.JPEGQuality)
+	default:
+		err = fmt.Errorf("unsupported variant format: %s", ext)
+	}
+	if err == nil {
+		err = d.Songs.CommitPreviewVariant(songID, cropID, tmp, path, masterHash)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// tempPath creates an empty, uniquely named file next to path for output
+// that is renamed over path once complete, so concurrent writers never share
+// a file. The dot prefix keeps it out of the preview_* and exercise_* globs.
+func tempPath(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// buildPreviewVariants eagerly produces every configured variant of a crop.
+func (d *Deps) buildPreviewVariants(jobID, songID, cropID string) {
+	exts := []string{"jpg"}
+	if d.Previews.CwebpPath != "" {
+		exts = append(exts, "webp")
+	}
+	for _, ext := range exts {
+		for _, width := range append([]int{0}, d.Previews.Widths...) {
+			if _, err := d.previewVariant(songID, cropID, width, ext); err != nil {
+				slog.Error("Failed to build preview variant", "job", jobID, "song", songID, "crop", cropID, "format", ext, "width", width, "err", err)
+				return
+			}
+		}
+	}
+}
+
+func writeJPEG(path string, img image.Image, quality int) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+	defer out.Close()
+
+	if err := jpeg.Encode(out, flattenWhite(img), &jpeg.Options{Quality: quality}); err != nil {
+		return fmt.Errorf("encode jpeg: %w", err)
+	}
+	return nil
+}
+
+// writeWebP encodes via the cwebp binary, the same way convertPDF shells out
+// to mutool/pdftoppm, since the standard library has no WebP encoder.
+func writeWebP(cwebpPath, path string, img image.Image, quality int) error {
+	pngPath, err := tempPath(path + ".png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(pngPath)
+	if err := writePNG(pngPath, img); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(cwebpPath, "-quiet", "-q", strconv.Itoa(quality), pngPath, "-o", path)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cwebp failed: %w", err)
+	}
+	return nil
+}
+
+// flattenWhite composites img onto an opaque white background, since JPEG
+// has no alpha channel.
+func flattenWhite(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Over)
+	return out
+}
+
+// resizeToWidth downscales img to the given width, preserving aspect ratio,
+// by averaging the source pixels that fall into each destination pixel.
+func resizeToWidth(img image.Image, width int) *image.RGBA {
+	src := flattenWhite(img)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * sh / height
+		y1 := (dy + 1) * sh / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * sw / width
+			x1 := (dx + 1) * sw / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, n int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4:]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					n++
+				}
+			}
+			o := dst.Pix[dy*dst.Stride+dx*4:]
+			o[0] = uint8(r / n)
+			o[1] = uint8(g / n)
+			o[2] = uint8(b / n)
+			o[3] = 255
+		}
+	}
+	return dst
+}
+
+// previewVariantContentType maps a variant extension to its MIME type.
+func previewVariantContentType(ext string) string {
+	if ext == "webp" {
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/prompts"
+)
+
+// analyzePromptData is the template data for the "analyze" prompts.
+type analyzePromptData struct {
+	PageCount int
+}
+
+// coachPromptData is the template data for the "coach" prompts.
+type coachPromptData struct {
+	History string // practice history, see coachHistory
+}
+
+// The "label" prompts get a LabelExercisesRequest and the "propose" prompts
+// a ProposeExercisesRequest, with PageCount set to the number of images sent.
+
+// renderPrompts renders the system and user prompt templates for an AI call.
+func (d *Deps) renderPrompts(name string, data any) (system, user string, err error) {
+	system, _, err = d.Prompts.Render(name+".system", data)
+	if err != nil {
+		return "", "", err
+	}
+	user, _, err = d.Prompts.Render(name+".user", data)
+	if err != nil {
+		return "", "", err
+	}
+	return system, user, nil
+}
+
+// PromptPreview is returned from GET /api/prompts/{name}/preview.
+type PromptPreview struct {
+	Name         string         `json:"name"`
+	JobID        string         `json:"jobId"`
+	PageCount    int            `json:"pageCount"` // page images that would be attached
+	System       string         `json:"system"`
+	User         string         `json:"user"`
+	SystemSource prompts.Source `json:"systemSource"`
+	UserSource   prompts.Source `json:"userSource"`
+}
+
+// HandleListPrompts lists the prompt templates and whether each is overridden.
+func (d *Deps) HandleListPrompts(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, d.Prompts.List())
+}
+
+// HandlePreviewPrompt renders a prompt exactly as it would be sent, without
+// calling the AI. Query: jobId for the pages, songId for title, sections and
+// exercises (required for "coach"; its jobId is used when jobId is omitted).
+func (d *Deps) HandlePreviewPrompt(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var song *models.Song
+	if songID := r.URL.Query().Get("songId"); songID != "" {
+		song, _ = d.Songs.Get(songID)
+		if song == nil {
+			jsonError(w, "Song not found", http.StatusNotFound)
+			return
+		}
+	}
+	jobID := r.URL.Query().Get("jobId")
+	if jobID == "" && song != nil {
+		jobID = song.JobID
+	}
+	pageCount := 0
+	if jobID != "" {
+		if !d.canReadJob(jobID) {
+			jsonError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		pageCount = min(d.Jobs.GetPageCount(jobID), 10)
+	}
+
+	var data any
+	switch name {
+	case "analyze":
+		data = analyzePromptData{PageCount: pageCount}
+	case "label":
+		req := LabelExercisesRequest{JobID: jobID, PageCount: pageCount}
+		if song != nil {
+			req.SongTitle, req.Artist = song.Title, song.Artist
+			req.Sections = labelSections(song)
+			for _, ex := range song.Exercises {
+				if ex.IsTransition {
+					continue
+				}
+				in := LabelExerciseInput{ID: ex.ID, CurrentName: ex.Name, CurrentSectionID: ex.SectionID, CurrentDifficulty: ex.Difficulty}
+				for _, c := range ex.Crops {
+					in.Crops = append(in.Crops, LabelCrop{PageIndex: c.PageIndex, Rect: c.Rect})
+				}
+				req.Exercises = append(req.Exercises, in)
+			}
+		}
+		data = req
+	case "propose":
+		req := ProposeExercisesRequest{JobID: jobID, PageCount: pageCount}
+		if song != nil {
+			req.SongTitle, req.Artist = song.Title, song.Artist
+			req.Sections = labelSections(song)
+		}
+		data = req
+	case "coach":
+		if song == nil {
+			jsonError(w, "songId is required for the coach prompt", http.StatusBadRequest)
+			return
+		}
+		history, err := d.songCoachHistory(song)
+		if err != nil {
+			jsonError(w, "Failed to load practice history", http.StatusInternalServerError)
+			return
+		}
+		data = coachPromptData{History: history}
+		pageCount = 0
+	default:
+		jsonError(w, "Unknown prompt", http.StatusNotFound)
+		return
+	}
+
+	preview := PromptPreview{Name: name, JobID: jobID, PageCount: pageCount}
+	var err error
+	if preview.System, preview.SystemSource, err = d.Prompts.Render(name+".system", data); err == nil {
+		preview.User, preview.UserSource, err = d.Prompts.Render(name+".user", data)
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	jsonOK(w, preview)
+}
+
+// labelSections converts a song's structure into prompt sections.
+func labelSections(song *models.Song) []LabelSection {
+	sections := make([]LabelSection, len(song.Structure))
+	for i, sec := range song.Structure {
+		sections[i] = LabelSection{ID: sec.ID, Type: sec.Type, Order: sec.Order}
+	}
+	return sections
+}
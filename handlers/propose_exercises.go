@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/LianHaeming/avoidnt/ai"
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+const (
+	maxProposedExercises = 40
+	minProposedRegion    = 0.02 // regions thinner than 2% of the page are noise
+)
+
+// ProposeExercisesRequest is the JSON body for POST /api/propose-exercises.
+type ProposeExercisesRequest struct {
+	SongTitle    string         `json:"songTitle"`
+	Artist       string         `json:"artist"`
+	PageImages   []string       `json:"pageImages"` // data URLs
+	JobID        string         `json:"jobId"`      // alternative to pageImages
+	PageCount    int            `json:"pageCount"`  // alternative to pageImages
+	Sections     []LabelSection `json:"sections"`   // existing structure to reuse
+	ForceRefresh bool           `json:"forceRefresh,omitempty"`
+}
+
+// ProposeExercisesResponse is a draft plan: the section structure (existing
+// sections plus any newly detected ones) and exercises with crops, in the
+// same shape as a saved song so the plan designer can load it directly.
+type ProposeExercisesResponse struct {
+	Sections  []models.Section   `json:"sections"`
+	Exercises []ProposedExercise `json:"exercises"`
+}
+
+// ProposedExercise is a draft exercise detected by the AI.
+type ProposedExercise struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	SectionID  string        `json:"sectionId"`
+	Crops      []models.Crop `json:"crops"`
+	Confidence string        `json:"confidence"`
+}
+
+// aiProposal is the validated model output, before IDs are assigned.
+type aiProposal struct {
+	Exercises []aiProposedExercise `json:"exercises"`
+}
+
+type aiProposedExercise struct {
+	Name       string           `json:"name"`
+	Section    string           `json:"section"`
+	Confidence string           `json:"confidence"`
+	Regions    []aiProposedRect `json:"regions"`
+}
+
+type aiProposedRect struct {
+	Page int     `json:"page"` // 1-based
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	W    float64 `json:"w"`
+	H    float64 `json:"h"`
+}
+
+// HandleProposeExercises asks the vision model to find musically meaningful
+// passages on the pages and returns them as draft exercises.
+func (d *Deps) HandleProposeExercises(w http.ResponseWriter, r *http.Request) {
+	if d.AI == nil {
+		jsonError(w, "AI provider not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req ProposeExercisesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var pageImages []string
+	if req.JobID != "" && req.PageCount > 0 {
+		imgs, err := d.loadJobPageImages(req.JobID, req.PageCount, 10)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		pageImages = imgs
+	} else {
+		pageImages = req.PageImages
+	}
+
+	if len(pageImages) == 0 {
+		jsonError(w, "No page images provided", http.StatusBadRequest)
+		return
+	}
+	if len(pageImages) > 10 {
+		pageImages = pageImages[:10]
+	}
+
+	input := struct {
+		SongTitle string
+		Artist    string
+		Sections  []LabelSection
+	}{req.SongTitle, req.Artist, req.Sections}
+	key := d.newAICacheKey("propose", req.JobID, pageImages, input)
+	proposal, err := cachedAICall(r.Context(), d, w, key, req.ForceRefresh, func() (*aiProposal, error) {
+		return d.callProposeExercisesAI(r.Context(), pageImages, req)
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "AI propose-exercises failed", "err", err)
+		aiError(w, err)
+		return
+	}
+
+	jsonOK(w, buildProposal(proposal, req.Sections, len(pageImages)))
+}
+
+// callProposeExercisesAI asks the model for bounding boxes of exercise passages.
+func (d *Deps) callProposeExercisesAI(ctx context.Context, pageImages []string, req ProposeExercisesRequest) (*aiProposal, error) {
+	req.PageCount = len(pageImages)
+	system, user, err := d.renderPrompts("propose", req)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []ai.Part{ai.Text(user)}
+	for _, img := range pageImages {
+		// Bounding boxes need more resolution than the low-detail default
+		parts = append(parts, ai.Image(img, "high"))
+	}
+
+	return ai.ChatJSON(ctx, d.AI, ai.ChatRequest{
+		Endpoint:    "propose",
+		MaxTokens:   3000,
+		Temperature: 0.1,
+		Schema:      &ai.Schema{Name: "exercise_proposal", Schema: json.RawMessage(proposeSchema)},
+		Messages: []ai.Message{
+			ai.System(system),
+			ai.User(parts...),
+		},
+	}, validateProposal)
+}
+
+const proposeSchema = `{
+  "type": "object",
+  "properties": {
+    "exercises": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "section": {"type": ["string", "null"]},
+          "confidence": {"type": "string", "enum": ["high", "medium", "low"]},
+          "regions": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "page": {"type": "integer"},
+                "x": {"type": "number"},
+                "y": {"type": "number"},
+                "w": {"type": "number"},
+                "h": {"type": "number"}
+              },
+              "required": ["page", "x", "y", "w", "h"],
+              "additionalProperties": false
+            }
+          }
+        },
+        "required": ["name", "section", "confidence", "regions"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["exercises"],
+  "additionalProperties": false
+}`
+
+// validateProposal enforces the confidence enum and limits names; regions
+// are clamped to the pages later, in buildProposal.
+func validateProposal(p *aiProposal) error {
+	for i := range p.Exercises {
+		if err := checkLevel(p.Exercises[i].Confidence); err != nil {
+			return fmt.Errorf("exercise %d: confidence %w", i+1, err)
+		}
+		p.Exercises[i].Name = limitName(p.Exercises[i].Name)
+	}
+	return nil
+}
+
+// buildProposal validates the model's regions, converts them to crops and
+// maps section names onto existing sections, creating new ones as needed.
+func buildProposal(p *aiProposal, existing []LabelSection, pageCount int) *ProposeExercisesResponse {
+	resp := &ProposeExercisesResponse{
+		Sections:  []models.Section{},
+		Exercises: []ProposedExercise{},
+	}
+
+	sectionByType := map[string]string{}
+	for _, sec := range existing {
+		resp.Sections = append(resp.Sections, models.Section{ID: sec.ID, Type: sec.Type, Order: sec.Order})
+		if _, ok := sectionByType[strings.ToLower(sec.Type)]; !ok {
+			sectionByType[strings.ToLower(sec.Type)] = sec.ID
+		}
+	}
+
+	for _, ex := range p.Exercises {
+		crops := proposedCrops(ex.Regions, pageCount)
+		if len(crops) == 0 {
+			continue
+		}
+
+		sectionID := ""
+		if t := normalizeSectionType(ex.Section); t != "" {
+			id, ok := sectionByType[t]
+			if !ok {
+				id = generateID()
+				sectionByType[t] = id
+				resp.Sections = append(resp.Sections, models.Section{ID: id, Type: t, Order: len(resp.Sections)})
+			}
+			sectionID = id
+		}
+
+		resp.Exercises = append(resp.Exercises, ProposedExercise{
+			ID:         generateID(),
+			Name:       ex.Name,
+			SectionID:  sectionID,
+			Crops:      crops,
+			Confidence: ex.Confidence,
+		})
+		if len(resp.Exercises) == maxProposedExercises {
+			break
+		}
+	}
+
+	return resp
+}
+
+// proposedCrops clamps regions to the page, drops degenerate or out-of-range
+// ones, and keeps at most two regions on consecutive pages (matching what
+// the plan designer lets users draw).
+func proposedCrops(regions []aiProposedRect, pageCount int) []models.Crop {
+	var crops []models.Crop
+	for _, reg := range regions {
+		if reg.Page < 1 || reg.Page > pageCount {
+			continue
+		}
+		x0, y0 := clamp01(reg.X), clamp01(reg.Y)
+		x1, y1 := clamp01(reg.X+reg.W), clamp01(reg.Y+reg.H)
+		if x1-x0 < minProposedRegion || y1-y0 < minProposedRegion {
+			continue
+		}
+		if len(crops) > 0 {
+			prev := crops[len(crops)-1].PageIndex
+			if reg.Page-1 != prev+1 {
+				break
+			}
+		}
+		crops = append(crops, models.Crop{
+			ID:        generateID(),
+			PageIndex: reg.Page - 1,
+			Rect:      models.Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0},
+		})
+		if len(crops) == 2 {
+			break
+		}
+	}
+	return crops
+}
+
+func clamp01(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, v))
+}
+
+// normalizeSectionType lowercases a section name and strips trailing
+// numbering ("Verse 2" -> "verse"), like the plan designer does.
+func normalizeSectionType(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "null" {
+		return ""
+	}
+	s = strings.TrimRight(s, "0123456789 ")
+	return strings.TrimSpace(s)
+}
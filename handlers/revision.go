@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// errBadIfMatch is returned for an If-Match header that isn't a song revision.
+var errBadIfMatch = errors.New("invalid If-Match header")
+
+// ifMatchRevisions parses the If-Match header into song revisions. A nil
+// result means the write is unconditional (no header, or "*").
+func ifMatchRevisions(r *http.Request) ([]int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	var revs []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		rev, err := strconv.Atoi(strings.Trim(tag, `"`))
+		if err != nil {
+			return nil, errBadIfMatch
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// revisionMatches reports whether song satisfies the If-Match revisions.
+func revisionMatches(song *models.Song, revs []int) bool {
+	if revs == nil {
+		return true
+	}
+	for _, rev := range revs {
+		if rev == song.Revision {
+			return true
+		}
+	}
+	return false
+}
+
+// songWriteError is returned from an update func to abort it with a response.
+type songWriteError struct {
+	msg  string
+	code int
+}
+
+func (e *songWriteError) Error() string { return e.msg }
+
+// conflictError aborts an update whose If-Match revision is stale.
+type conflictError struct {
+	current models.Song
+}
+
+func (e *conflictError) Error() string { return "revision conflict" }
+
+// updateSong runs fn as an atomic read-modify-write of a song, honoring the
+// request's If-Match header. It writes the error response itself and returns
+// nil on failure; on success it sets the ETag header to the new revision.
+func (d *Deps) updateSong(w http.ResponseWriter, r *http.Request, songID string, fn func(song *models.Song) error) *models.Song {
+	revs, err := ifMatchRevisions(r)
+	if err != nil {
+		jsonError(w, "Invalid If-Match header", http.StatusBadRequest)
+		return nil
+	}
+	song, err := d.Songs.Update(songID, func(song *models.Song) error {
+		if !revisionMatches(song, revs) {
+			return &conflictError{current: *song}
+		}
+		return fn(song)
+	})
+	if err != nil {
+		d.songWriteFailed(w, r, songID, err)
+		return nil
+	}
+	w.Header().Set("ETag", song.ETag())
+	return song
+}
+
+// songWriteFailed maps an error from Songs.Update or Songs.Put to a response.
+func (d *Deps) songWriteFailed(w http.ResponseWriter, r *http.Request, songID string, err error) {
+	var conflict *conflictError
+	var writeErr *songWriteError
+	switch {
+	case errors.As(err, &conflict):
+		songConflict(w, &conflict.current)
+	case errors.As(err, &writeErr):
+		jsonError(w, writeErr.msg, writeErr.code)
+	case errors.Is(err, storage.ErrSongNotFound):
+		jsonError(w, "Song not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrInvalidID):
+		jsonError(w, "Invalid song, exercise or crop ID", http.StatusBadRequest)
+	default:
+		slog.ErrorContext(r.Context(), "Failed to save song", "song", songID, "err", err)
+		jsonError(w, "Failed to save", http.StatusInternalServerError)
+	}
+}
+
+// songConflict answers a stale If-Match with 409 and the current song, so
+// the client can rebase its change and retry.
+func songConflict(w http.ResponseWriter, current *models.Song) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", current.ETag())
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":    "The song was changed by someone else. Reload to get the latest version.",
+		"code":     "revision_conflict",
+		"revision": current.Revision,
+		"song":     current,
+	})
+}
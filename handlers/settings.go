@@ -58,9 +58,6 @@ func (d *Deps) HandleUpdateSettings(w http.ResponseWriter, r *http.Request) {
 			jsonError(w, "displayName must be at most 30 characters", http.StatusBadRequest)
 			return
 		}
-		if name == "" {
-			name = "Lian"
-		}
 		settings.DisplayName = name
 	}
 
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// maxShareLinkDays caps how far in the future a share link can expire.
+const maxShareLinkDays = 365
+
+// signShareLink builds the public token for a share link: the owner, song
+// and link IDs, followed by an HMAC over them so tokens can't be forged or
+// pointed at another song.
+func (d *Deps) signShareLink(ownerID, songID, linkID string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(ownerID + ":" + songID + ":" + linkID))
+	return payload + "." + d.shareSignature(payload)
+}
+
+func (d *Deps) shareSignature(payload string) string {
+	mac := hmac.New(sha256.New, d.ShareKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:18])
+}
+
+// parseShareLink verifies a share token and returns the IDs it was signed for.
+func (d *Deps) parseShareLink(token string) (ownerID, songID, linkID string, ok bool) {
+	payload, sig, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(sig), []byte(d.shareSignature(payload))) {
+		return "", "", "", false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", "", false
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// ShareLinkInfo is a share link as shown to the song's owner.
+type ShareLinkInfo struct {
+	models.ShareLink
+	URL     string `json:"url"`
+	Expired bool   `json:"expired"`
+}
+
+func (d *Deps) shareLinkInfo(songID string, l models.ShareLink, now time.Time) ShareLinkInfo {
+	return ShareLinkInfo{
+		ShareLink: l,
+		URL:       "/shared/" + d.signShareLink(d.User.ID, songID, l.ID),
+		Expired:   l.Expired(now),
+	}
+}
+
+// HandleListShareLinks returns a song's public share links.
+func (d *Deps) HandleListShareLinks(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+	links, err := d.Songs.ListShareLinks(songID)
+	if err != nil {
+		jsonError(w, "Failed to load share links", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	result := make([]ShareLinkInfo, len(links))
+	for i, l := range links {
+		result[i] = d.shareLinkInfo(songID, l, now)
+	}
+	jsonOK(w, result)
+}
+
+// CreateShareLinkRequest is the JSON body for POST /api/songs/{songId}/share-links.
+type CreateShareLinkRequest struct {
+	ExpiresInDays int `json:"expiresInDays"` // 0 = never
+}
+
+// HandleCreateShareLink creates a public link to the song's read-only view.
+func (d *Deps) HandleCreateShareLink(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+
+	var req CreateShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxShareLinkDays {
+		jsonError(w, "expiresInDays must be between 0 and 365", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	link := models.ShareLink{ID: generateID(), CreatedAt: now.Format(time.RFC3339)}
+	if req.ExpiresInDays > 0 {
+		exp := now.AddDate(0, 0, req.ExpiresInDays).Format(time.RFC3339)
+		link.ExpiresAt = &exp
+	}
+
+	if err := d.Songs.AddShareLink(songID, link); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			jsonError(w, "Song not found", http.StatusNotFound)
+		} else {
+			jsonError(w, "Failed to save", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonOK(w, map[string]any{"success": true, "link": d.shareLinkInfo(songID, link, now)})
+}
+
+// HandleDeleteShareLink revokes a public share link.
+func (d *Deps) HandleDeleteShareLink(w http.ResponseWriter, r *http.Request) {
+	if err := d.Songs.DeleteShareLink(r.PathValue("songId"), r.PathValue("linkId")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			jsonError(w, "Share link not found", http.StatusNotFound)
+		} else {
+			jsonError(w, "Failed to revoke", http.StatusInternalServerError)
+		}
+		return
+	}
+	jsonOK(w, map[string]any{"success": true})
+}
+
+// publicShare resolves the /shared/{token} route to the owner's deps and the
+// song. count records a page view. It writes the error response itself and
+// returns a nil song when the link is forged, revoked or expired.
+func (d *Deps) publicShare(w http.ResponseWriter, r *http.Request, count bool) (*Deps, *models.Song) {
+	ownerID, songID, linkID, ok := d.parseShareLink(r.PathValue("token"))
+	if !ok {
+		http.NotFound(w, r)
+		return nil, nil
+	}
+	owner, err := d.Users.Get(ownerID)
+	if err != nil || owner == nil {
+		http.NotFound(w, r)
+		return nil, nil
+	}
+	ownerDeps := d.forUser(owner)
+
+	var link *models.ShareLink
+	if count {
+		link, err = ownerDeps.Songs.RecordShareAccess(songID, linkID, time.Now())
+	} else {
+		link, err = ownerDeps.Songs.GetShareLink(songID, linkID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to load link", http.StatusInternalServerError)
+		return nil, nil
+	}
+	if link == nil {
+		http.NotFound(w, r)
+		return nil, nil
+	}
+	if link.Expired(time.Now()) {
+		http.Error(w, "This link has expired", http.StatusGone)
+		return nil, nil
+	}
+
+	song, err := ownerDeps.Songs.Get(songID)
+	if err != nil {
+		http.Error(w, "Failed to load song", http.StatusInternalServerError)
+		return nil, nil
+	}
+	if song == nil {
+		http.NotFound(w, r)
+		return nil, nil
+	}
+	return ownerDeps, song
+}
+
+// HandlePublicSong renders a song's read-only view for anyone holding a
+// share link, signed in or not.
+func (d *Deps) HandlePublicSong(w http.ResponseWriter, r *http.Request) {
+	ownerDeps, song := d.publicShare(w, r, true)
+	if song == nil {
+		return
+	}
+
+	// The token is the credential; keep it out of referrers and search indexes
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	data := ownerDeps.songDetailData(song, ownerDeps.Settings.Get())
+	data.PreviewBase = "/shared/" + r.PathValue("token")
+	data.ReadOnly = true
+	data.Public = true
+	data.Owner = ownerDeps.User.Username
+	d.render(w, "song-detail.html", data)
+}
+
+// HandlePublicPreview serves a crop preview for the public view.
+func (d *Deps) HandlePublicPreview(w http.ResponseWriter, r *http.Request) {
+	if !validPathIDs(r) {
+		http.NotFound(w, r)
+		return
+	}
+	ownerDeps, song := d.publicShare(w, r, false)
+	if song == nil {
+		return
+	}
+	r.SetPathValue("songId", song.ID)
+	ownerDeps.HandlePreview(w, r)
+}
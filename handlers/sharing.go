@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// ShareSongRequest is the JSON body for PUT /api/songs/{songId}/share.
+type ShareSongRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// sharedSongURL is the read-only view of a song in a user's library.
+func sharedSongURL(ownerID, songID string) string {
+	return "/users/" + ownerID + "/songs/" + songID
+}
+
+// HandleShareSong turns the read-only link for a song on or off.
+func (d *Deps) HandleShareSong(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+
+	var req ShareSongRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	song := d.updateSong(w, r, songID, func(song *models.Song) error {
+		song.SharedReadOnly = req.Enabled
+		return nil
+	})
+	if song == nil {
+		return
+	}
+	d.publish(Event{Type: EventSong, SongID: songID, Revision: song.Revision})
+
+	jsonOK(w, map[string]any{
+		"success":        true,
+		"sharedReadOnly": song.SharedReadOnly,
+		"revision":       song.Revision,
+		"url":            sharedSongURL(d.User.ID, song.ID),
+	})
+}
+
+// canViewSong reports whether the signed-in user may open another library's
+// song read-only: the owner shared it, or the viewer is the owner's teacher.
+func (d *Deps) canViewSong(owner *models.User, song *models.Song) bool {
+	return song.SharedReadOnly || owner.HasTeacher(d.User.ID)
+}
+
+// sharedSong resolves the owner and song of a /users/{userId}/songs/{songId}
+// route. It returns nil deps when the song doesn't exist or can't be viewed,
+// so unshared songs are indistinguishable from missing ones.
+func (d *Deps) sharedSong(r *http.Request) (*Deps, *models.Song, error) {
+	owner, err := d.Users.Get(r.PathValue("userId"))
+	if err != nil || owner == nil {
+		return nil, nil, err
+	}
+	ownerDeps := d.forUser(owner)
+	song, err := ownerDeps.Songs.Get(r.PathValue("songId"))
+	if err != nil || song == nil {
+		return nil, nil, err
+	}
+	if owner.ID != d.User.ID && !d.canViewSong(owner, song) {
+		return nil, nil, nil
+	}
+	return ownerDeps, song, nil
+}
+
+// HandleSharedSong renders the read-only view of a song in another user's library.
+func (d *Deps) HandleSharedSong(w http.ResponseWriter, r *http.Request) {
+	ownerDeps, song, err := d.sharedSong(r)
+	if err != nil {
+		http.Error(w, "Failed to load song", http.StatusInternalServerError)
+		return
+	}
+	if song == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if ownerDeps.User.ID == d.User.ID {
+		http.Redirect(w, r, "/songs/"+song.ID, http.StatusFound)
+		return
+	}
+
+	// The read-only view links the song's PDF
+	if song.JobID != "" {
+		d.Jobs.Grant(song.JobID, d.User.ID)
+	}
+
+	// Stage names come from the owner, theme from the viewer
+	settings := ownerDeps.Settings.Get()
+	settings.Theme = d.Settings.Get().Theme
+
+	data := ownerDeps.songDetailData(song, settings)
+	data.PreviewBase = sharedSongURL(ownerDeps.User.ID, song.ID)
+	data.ReadOnly = true
+	data.Owner = ownerDeps.User.Username
+	d.render(w, "song-detail.html", data)
+}
+
+// HandleSharedPreview serves a crop preview for the read-only view.
+func (d *Deps) HandleSharedPreview(w http.ResponseWriter, r *http.Request) {
+	ownerDeps, song, err := d.sharedSong(r)
+	if err != nil || song == nil {
+		http.NotFound(w, r)
+		return
+	}
+	ownerDeps.HandlePreview(w, r)
+}
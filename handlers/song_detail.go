@@ -19,7 +19,13 @@ type SongDetailData struct {
 	LastPracticed     *string // most recent lastPracticedAt
 	StageCounts       [5]int  // count of exercises at each stage (index 0 = stage 1)
 	ExerciseCount     int
-	EditMode          bool // true when entering edit mode
+	EditMode          bool   // true when entering edit mode
+	PreviewWidths     []int  // widths available for responsive preview srcsets
+	PreviewBase       string // URL prefix for crop previews ({PreviewBase}/preview/{cropId})
+	ReadOnly          bool   // shared view: no edit, practice or stats controls
+	Public            bool   // anonymous share-link view: also hides the PDF and app navigation
+	Owner             string // username of the library owner, set for read-only views
+	ShareURL          string // path of the song's read-only view, for the owner's share dialog
 }
 
 // SectionGroup groups exercises under a section label.
@@ -47,11 +53,18 @@ func (d *Deps) HandleSongDetail(w http.ResponseWriter, r *http.Request) {
 	// Migrate existing practice data to daily/stage logs if needed
 	d.migrateExistingData(song)
 
-	settings := d.Settings.Get()
-	groups := buildSectionGroups(song)
-
+	data := d.songDetailData(song, d.Settings.Get())
+	data.PreviewBase = "/api/songs/" + song.ID
+	data.ShareURL = sharedSongURL(d.User.ID, song.ID)
 	// Check if entering edit mode via query param or /edit path
-	editMode := r.URL.Query().Get("edit") == "1"
+	data.EditMode = r.URL.Query().Get("edit") == "1"
+
+	d.render(w, "song-detail.html", data)
+}
+
+// songDetailData builds the song detail template data with aggregate stats.
+func (d *Deps) songDetailData(song *models.Song, settings models.UserSettings) SongDetailData {
+	groups := buildSectionGroups(song)
 
 	// Compute aggregate stats
 	var totalTime, totalReps int
@@ -64,7 +77,7 @@ func (d *Deps) HandleSongDetail(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data := SongDetailData{
+	return SongDetailData{
 		Settings:          settings,
 		Song:              *song,
 		SectionGroups:     groups,
@@ -73,10 +86,8 @@ func (d *Deps) HandleSongDetail(w http.ResponseWriter, r *http.Request) {
 		LastPracticed:     song.LastPracticed(),
 		StageCounts:       stageCounts,
 		ExerciseCount:     len(song.Exercises),
-		EditMode:          editMode,
+		PreviewWidths:     d.Previews.Widths,
 	}
-
-	d.render(w, "song-detail.html", data)
 }
 
 // HandleSongDetailEdit redirects /songs/{songId}/edit to the song detail page in edit mode.
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+var (
+	keyPattern     = regexp.MustCompile(`^([A-Ga-g])([#b♯♭]?)\s*(m|min|maj|major|minor)?$`)
+	timeSigPattern = regexp.MustCompile(`^(\d{1,2})\s*/\s*(1|2|4|8|16|32)$`)
+)
+
+// songModes are the accepted values of Song.Mode.
+var songModes = map[string]string{
+	"major": "major", "maj": "major", "ionian": "major",
+	"minor": "minor", "min": "minor", "m": "minor", "aeolian": "minor",
+	"dorian": "dorian", "phrygian": "phrygian", "lydian": "lydian",
+	"mixolydian": "mixolydian", "locrian": "locrian",
+}
+
+// normalizeSongMeta canonicalizes key, mode, time signature, capo and tuning
+// in place, returning an error naming the first field that makes no sense.
+// Blank values are cleared. A key written with its mode ("Em", "F# minor")
+// fills in Mode when Mode is empty.
+func normalizeSongMeta(song *models.Song) error {
+	if song.Key != nil {
+		key, mode, ok := normalizeKey(*song.Key)
+		if !ok {
+			return fmt.Errorf("invalid key %q (expected e.g. \"E\", \"F#\" or \"Bb\")", *song.Key)
+		}
+		song.Key = optString(key)
+		if mode != "" && (song.Mode == nil || strings.TrimSpace(*song.Mode) == "") {
+			song.Mode = &mode
+		}
+	}
+	if song.Mode != nil {
+		mode, ok := normalizeMode(*song.Mode)
+		if !ok {
+			return fmt.Errorf("invalid mode %q", *song.Mode)
+		}
+		song.Mode = optString(mode)
+	}
+	if song.TimeSignature != nil {
+		ts, ok := normalizeTimeSignature(*song.TimeSignature)
+		if !ok {
+			return fmt.Errorf("invalid time signature %q (expected e.g. \"4/4\")", *song.TimeSignature)
+		}
+		song.TimeSignature = optString(ts)
+	}
+	if song.Capo != nil {
+		if *song.Capo < 0 || *song.Capo > 24 {
+			return fmt.Errorf("invalid capo %d (expected a fret from 0 to 24)", *song.Capo)
+		}
+		if *song.Capo == 0 {
+			song.Capo = nil
+		}
+	}
+	if song.Tuning != nil {
+		song.Tuning = optString(limitName(*song.Tuning))
+	}
+	return nil
+}
+
+// normalizeKey parses a tonic with an optional mode suffix: "e" -> "E",
+// "f#m" -> "F#" + "minor", "B♭ major" -> "Bb" + "major". An empty key is valid.
+func normalizeKey(s string) (key, mode string, ok bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", "", true
+	}
+	if i := strings.IndexAny(s, " \t"); i > 0 {
+		if m, ok := normalizeMode(s[i:]); ok && m != "" {
+			key, _, ok := normalizeKey(s[:i])
+			return key, m, ok
+		}
+	}
+	m := keyPattern.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", false
+	}
+	key = strings.ToUpper(m[1])
+	switch m[2] {
+	case "#", "♯":
+		key += "#"
+	case "b", "♭":
+		key += "b"
+	}
+	if m[3] != "" {
+		mode = songModes[m[3]]
+	}
+	return key, mode, true
+}
+
+// normalizeMode maps a mode name or abbreviation onto songModes.
+func normalizeMode(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return "", true
+	}
+	m, ok := songModes[s]
+	return m, ok
+}
+
+// normalizeTimeSignature accepts "N/D" with a power-of-two denominator,
+// plus "C" (common time) and "¢" (cut time).
+func normalizeTimeSignature(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "":
+		return "", true
+	case "C", "c":
+		return "4/4", true
+	case "¢":
+		return "2/2", true
+	}
+	m := timeSigPattern.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	if n, _ := strconv.Atoi(m[1]); n < 1 {
+		return "", false
+	}
+	return m[1] + "/" + m[2], true
+}
+
+// optString returns nil for an empty string.
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -59,6 +59,7 @@ func (d *Deps) HandlePatchDailyLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	d.publish(Event{Type: EventDailyLog, SongID: songID, Data: req})
 	jsonOK(w, map[string]any{"success": true})
 }
 
@@ -76,26 +77,21 @@ func (d *Deps) HandleToggleTransition(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	song, err := d.Songs.Get(songID)
-	if err != nil || song == nil {
-		jsonError(w, "Song not found", http.StatusNotFound)
-		return
-	}
-
-	// Find existing transition or create new one
-	found := false
-	for i := range song.Exercises {
-		ex := &song.Exercises[i]
-		if ex.IsTransition &&
-			((ex.TransitionBetween[0] == req.ExerciseID1 && ex.TransitionBetween[1] == req.ExerciseID2) ||
-				(ex.TransitionBetween[0] == req.ExerciseID2 && ex.TransitionBetween[1] == req.ExerciseID1)) {
-			ex.IsTracked = req.Track
-			found = true
-			break
+	song := d.updateSong(w, r, songID, func(song *models.Song) error {
+		// Find existing transition or create new one
+		for i := range song.Exercises {
+			ex := &song.Exercises[i]
+			if ex.IsTransition &&
+				((ex.TransitionBetween[0] == req.ExerciseID1 && ex.TransitionBetween[1] == req.ExerciseID2) ||
+					(ex.TransitionBetween[0] == req.ExerciseID2 && ex.TransitionBetween[1] == req.ExerciseID1)) {
+				ex.IsTracked = req.Track
+				return nil
+			}
+		}
+		if !req.Track {
+			return nil
 		}
-	}
 
-	if !found && req.Track {
 		// Create a new transition exercise
 		// Find names of the two exercises
 		var name1, name2 string
@@ -120,14 +116,15 @@ func (d *Deps) HandleToggleTransition(w http.ResponseWriter, r *http.Request) {
 			CreatedAt:         time.Now().UTC().Format(time.RFC3339),
 		}
 		song.Exercises = append(song.Exercises, newEx)
-	}
-
-	if err := d.Songs.Save(song); err != nil {
-		jsonError(w, "Failed to save", http.StatusInternalServerError)
+		return nil
+	})
+	if song == nil {
 		return
 	}
 
-	jsonOK(w, map[string]any{"success": true})
+	d.publish(Event{Type: EventSong, SongID: songID, Revision: song.Revision})
+
+	jsonOK(w, map[string]any{"success": true, "revision": song.Revision})
 }
 
 // HandleGetStageLog returns the stage change history for a song.
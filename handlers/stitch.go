@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// maxStitchSpacing caps the gap between stacked crops, in pixels.
+const maxStitchSpacing = 200
+
+// HandleExerciseImage serves all crops of an exercise composed into a single
+// image, stacked vertically on the song's crop background color.
+// Supports optional ?spacing=N (pixels between crops, default 0).
+func (d *Deps) HandleExerciseImage(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+	exerciseID := r.PathValue("exerciseId")
+
+	spacing := 0
+	if v := r.URL.Query().Get("spacing"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxStitchSpacing {
+			http.Error(w, fmt.Sprintf("spacing must be 0-%d", maxStitchSpacing), http.StatusBadRequest)
+			return
+		}
+		spacing = n
+	}
+
+	song, err := d.Songs.Get(songID)
+	if err != nil || song == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var exercise *models.Exercise
+	for i := range song.Exercises {
+		if song.Exercises[i].ID == exerciseID {
+			exercise = &song.Exercises[i]
+			break
+		}
+	}
+	if exercise == nil || len(exercise.Crops) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	path, err := d.exerciseImage(song, exercise, spacing)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hash, err := storage.FileHash(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	setContentCacheHeaders(w, r, hash)
+	http.ServeFile(w, r, path)
+}
+
+// exerciseImage returns the path of the stitched image for an exercise,
+// building and caching it on disk if the cached copy is missing or older
+// than any of its crop previews.
+func (d *Deps) exerciseImage(song *models.Song, ex *models.Exercise, spacing int) (string, error) {
+	bg := parseHexColor(song.CropBgColor)
+	path := d.Songs.ExerciseImagePath(song.ID, ex.ID, stitchKey(ex, spacing, bg))
+
+	if cacheInfo, err := os.Stat(path); err == nil {
+		fresh := true
+		for _, crop := range ex.Crops {
+			info, err := os.Stat(d.Songs.PreviewPath(song.ID, crop.ID))
+			if err != nil || info.ModTime().After(cacheInfo.ModTime()) {
+				fresh = false
+				break
+			}
+		}
+		if fresh {
+			return path, nil
+		}
+	}
+
+	var imgs []image.Image
+	for _, crop := range ex.Crops {
+		img, err := decodeImageFile(d.Songs.PreviewPath(song.ID, crop.ID))
+		if err != nil {
+			return "", fmt.Errorf("crop %s: %w", crop.ID, err)
+		}
+		imgs = append(imgs, img)
+	}
+
+	// Build in a temp file and rename it into place, so a request serving the
+	// cached copy never reads a half-written PNG
+	tmp, err := tempPath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := writePNG(tmp, stitchImages(imgs, spacing, bg)); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	d.Songs.ClearExerciseImages(song.ID, ex.ID)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// stitchImages stacks images vertically, left-aligned, with spacing pixels
+// between them. The canvas is as wide as the widest image and filled with bg.
+func stitchImages(imgs []image.Image, spacing int, bg color.Color) image.Image {
+	width, height := 0, 0
+	for i, img := range imgs {
+		b := img.Bounds()
+		if b.Dx() > width {
+			width = b.Dx()
+		}
+		height += b.Dy()
+		if i > 0 {
+			height += spacing
+		}
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(out, out.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	y := 0
+	for _, img := range imgs {
+		b := img.Bounds()
+		draw.Draw(out, image.Rect(0, y, b.Dx(), y+b.Dy()), img, b.Min, draw.Over)
+		y += b.Dy() + spacing
+	}
+	return out
+}
+
+// stitchKey hashes everything that affects a stitched image's pixels
+// except the preview contents themselves (covered by modification times).
+func stitchKey(ex *models.Exercise, spacing int, bg color.Color) string {
+	h := sha256.New()
+	for _, crop := range ex.Crops {
+		fmt.Fprintf(h, "%s|%d|%g,%g,%g,%g;", crop.ID, crop.PageIndex, crop.Rect.X, crop.Rect.Y, crop.Rect.W, crop.Rect.H)
+	}
+	r, g, b, a := bg.RGBA()
+	fmt.Fprintf(h, "spacing=%d;bg=%d,%d,%d,%d", spacing, r, g, b, a)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// parseHexColor parses a "#rrggbb" or "#rgb" color, defaulting to white.
+func parseHexColor(s *string) color.Color {
+	white := color.RGBA{255, 255, 255, 255}
+	if s == nil {
+		return white
+	}
+	hexStr := strings.TrimPrefix(strings.TrimSpace(*s), "#")
+	if len(hexStr) == 3 {
+		hexStr = string([]byte{hexStr[0], hexStr[0], hexStr[1], hexStr[1], hexStr[2], hexStr[2]})
+	}
+	if len(hexStr) != 6 {
+		return white
+	}
+	v, err := strconv.ParseUint(hexStr, 16, 32)
+	if err != nil {
+		return white
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+	"github.com/LianHaeming/avoidnt/storage"
+)
+
+// maxSyncOps caps the operations accepted in one sync request.
+const maxSyncOps = 500
+
+// Sync operation types.
+const (
+	SyncPractice = "practice" // add Seconds and Reps to an exercise
+	SyncStage    = "stage"    // set an exercise's Stage
+)
+
+// Sync result statuses.
+const (
+	SyncApplied   = "applied"
+	SyncDuplicate = "duplicate" // the ID was handled by an earlier request
+	SyncConflict  = "conflict"  // superseded by a newer change; Exercise is the server's copy
+	SyncRejected  = "rejected"  // invalid, or the song or exercise is gone; don't retry
+	SyncFailed    = "error"     // server error; retry later
+)
+
+// SyncOp is one change queued by a client while it may have been offline.
+type SyncOp struct {
+	ID         string `json:"id"` // client-generated, unique per operation
+	Type       string `json:"type"`
+	SongID     string `json:"songId"`
+	ExerciseID string `json:"exerciseId"`
+	At         string `json:"at"` // when it happened on the client, RFC 3339
+	Seconds    int    `json:"seconds,omitempty"`
+	Reps       int    `json:"reps,omitempty"`
+	Stage      int    `json:"stage,omitempty"`
+}
+
+// SyncRequest is the JSON body for POST /api/sync.
+type SyncRequest struct {
+	Operations []SyncOp `json:"operations"`
+}
+
+// fingerprint identifies what an operation does, so an ID reused for a
+// different operation is caught instead of being reported as a duplicate.
+func (op SyncOp) fingerprint() string {
+	return fmt.Sprintf("%s:%s:%s:%d:%d:%d", op.Type, op.SongID, op.ExerciseID, op.Seconds, op.Reps, op.Stage)
+}
+
+// SyncResult reports what happened to one operation.
+type SyncResult struct {
+	ID       string           `json:"id"`
+	Status   string           `json:"status"`
+	Error    string           `json:"error,omitempty"`
+	Revision int              `json:"revision,omitempty"` // song revision after an applied op
+	Exercise *models.Exercise `json:"exercise,omitempty"`
+
+	code int // HTTP status for a rejected or failed op
+}
+
+// errSyncNoop ends a song update that would change nothing.
+var errSyncNoop = errors.New("no change")
+
+// HandleSync applies a batch of queued client operations in order. Each
+// operation is applied at most once per user, however often it is resent.
+func (d *Deps) HandleSync(w http.ResponseWriter, r *http.Request) {
+	var req SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) > maxSyncOps {
+		jsonError(w, "Too many operations (max 500)", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	results := make([]SyncResult, len(req.Operations))
+	for i, op := range req.Operations {
+		results[i] = d.applySyncOp(r.Context(), op, now)
+	}
+	jsonOK(w, map[string]any{"success": true, "results": results})
+}
+
+func (d *Deps) applySyncOp(ctx context.Context, op SyncOp, now time.Time) SyncResult {
+	res := SyncResult{ID: op.ID}
+	reject := func(msg string, code int) SyncResult {
+		res.Status = SyncRejected
+		res.Error = msg
+		res.code = code
+		return res
+	}
+
+	if op.ID == "" || op.SongID == "" || op.ExerciseID == "" {
+		return reject("Missing required fields (id, songId, exerciseId)", http.StatusBadRequest)
+	}
+	if !models.ValidID(op.SongID) || !models.ValidID(op.ExerciseID) {
+		return reject("Song not found", http.StatusNotFound)
+	}
+	at, err := time.Parse(time.RFC3339, op.At)
+	if err != nil {
+		return reject("Invalid timestamp", http.StatusBadRequest)
+	}
+	if now.Sub(at) > storage.SyncOpRetention {
+		return reject("Operation is too old to sync", http.StatusBadRequest)
+	}
+	if at.After(now) {
+		at = now // client clock ahead
+	}
+	switch op.Type {
+	case SyncPractice:
+		if op.Seconds < 0 || op.Reps < 0 || op.Seconds+op.Reps == 0 {
+			return reject("Practice needs positive seconds or reps", http.StatusBadRequest)
+		}
+	case SyncStage:
+		if op.Stage < 1 || op.Stage > 5 {
+			return reject("Stage must be between 1 and 5", http.StatusBadRequest)
+		}
+	default:
+		return reject("Unknown operation type", http.StatusBadRequest)
+	}
+
+	var events []Event
+	prev, dup, err := d.SyncOps.Apply(op.ID, op.fingerprint(), now, func() (status string, err error) {
+		if op.Type == SyncPractice {
+			status, events, err = d.syncPractice(op, at, &res)
+		} else {
+			status, events, err = d.syncStage(ctx, op, at, &res)
+		}
+		return status, err
+	})
+	var writeErr *songWriteError
+	switch {
+	case dup && prev.Fingerprint != "" && prev.Fingerprint != op.fingerprint():
+		return reject("This ID was already used for a different change", http.StatusUnprocessableEntity)
+	case dup:
+		res.Status = SyncDuplicate
+	case errors.Is(err, storage.ErrSongNotFound):
+		return reject("Song not found", http.StatusNotFound)
+	case errors.As(err, &writeErr):
+		return reject(writeErr.msg, writeErr.code)
+	case err != nil:
+		slog.ErrorContext(ctx, "Sync op failed", "op", op.ID, "err", err)
+		res.Status = SyncFailed
+		res.Error = "Failed to save"
+		res.code = http.StatusInternalServerError
+	default:
+		for _, ev := range events {
+			d.publish(ev)
+		}
+	}
+	return res
+}
+
+// syncPractice adds a practice op's time and reps to the exercise and the
+// day's log.
+func (d *Deps) syncPractice(op SyncOp, at time.Time, res *SyncResult) (string, []Event, error) {
+	song, logReq, err := d.recordPractice(op.SongID, op.ExerciseID, op.Seconds, op.Reps, at)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ex := song.FindExercise(op.ExerciseID)
+	res.Status = SyncApplied
+	res.Revision = song.Revision
+	res.Exercise = ex
+	return SyncApplied, []Event{
+		{Type: EventExercise, SongID: op.SongID, Revision: song.Revision, Data: ex},
+		{Type: EventDailyLog, SongID: op.SongID, Data: logReq},
+	}, nil
+}
+
+// syncStage sets an exercise's stage, unless the stage was changed after the
+// op happened: the newer change wins and the op is reported as a conflict.
+func (d *Deps) syncStage(ctx context.Context, op SyncOp, at time.Time, res *SyncResult) (string, []Event, error) {
+	stageLog, err := d.StageLogs.GetAll(op.SongID)
+	if err != nil {
+		return "", nil, err
+	}
+	var newer bool
+	for _, e := range stageLog {
+		if t, err := time.Parse(time.RFC3339, e.Timestamp); err == nil && e.ExerciseID == op.ExerciseID && t.After(at) {
+			newer = true
+			break
+		}
+	}
+
+	var current models.Exercise
+	song, err := d.Songs.Update(op.SongID, func(song *models.Song) error {
+		ex := song.FindExercise(op.ExerciseID)
+		if ex == nil {
+			return &songWriteError{"Exercise not found", http.StatusNotFound}
+		}
+		current = *ex
+		if newer || ex.Stage == op.Stage {
+			return errSyncNoop
+		}
+		ex.Stage = op.Stage
+		return nil
+	})
+	if errors.Is(err, errSyncNoop) {
+		res.Exercise = &current
+		if newer && current.Stage != op.Stage {
+			res.Status = SyncConflict
+			return SyncConflict, nil, nil
+		}
+		res.Status = SyncApplied
+		return SyncApplied, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Log the change when it happened, so a later sync of an older op conflicts
+	entry := models.StageLogEntry{
+		ExerciseID: op.ExerciseID,
+		Stage:      op.Stage,
+		Timestamp:  at.UTC().Format(time.RFC3339),
+	}
+	if err := d.StageLogs.Insert(op.SongID, entry); err != nil {
+		slog.ErrorContext(ctx, "Failed to log stage change", "song", op.SongID, "err", err)
+	}
+
+	ex := song.FindExercise(op.ExerciseID)
+	res.Status = SyncApplied
+	res.Revision = song.Revision
+	res.Exercise = ex
+	return SyncApplied, []Event{
+		{Type: EventExercise, SongID: op.SongID, Revision: song.Revision, Data: ex},
+		{Type: EventStageLog, SongID: op.SongID, Data: entry},
+	}, nil
+}
+
+// HandleServiceWorker serves the offline service worker from the site root,
+// so its scope covers every page.
+func (d *Deps) HandleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFileFS(w, r, d.Static, "sw.js")
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// AccountInfo is the public part of an account, as shown to teachers and students.
+type AccountInfo struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// teachersOf resolves a user's teacher IDs, skipping accounts that no longer exist.
+func (d *Deps) teachersOf(user *models.User) []AccountInfo {
+	teachers := []AccountInfo{}
+	for _, id := range user.Teachers {
+		t, err := d.Users.Get(id)
+		if err != nil || t == nil {
+			continue
+		}
+		teachers = append(teachers, AccountInfo{ID: t.ID, Username: t.Username})
+	}
+	return teachers
+}
+
+// HandleListTeachers returns the accounts allowed to assign songs to the current user.
+func (d *Deps) HandleListTeachers(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, d.teachersOf(d.User))
+}
+
+// AddTeacherRequest is the JSON body for POST /api/teachers.
+type AddTeacherRequest struct {
+	Username string `json:"username"`
+}
+
+// HandleAddTeacher lets another account assign songs to the current user and
+// see their progress on them.
+func (d *Deps) HandleAddTeacher(w http.ResponseWriter, r *http.Request) {
+	var req AddTeacherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	teacher, err := d.Users.GetByUsername(strings.TrimSpace(req.Username))
+	if err != nil {
+		jsonError(w, "Failed to load account", http.StatusInternalServerError)
+		return
+	}
+	if teacher == nil {
+		jsonError(w, "No account with that username", http.StatusNotFound)
+		return
+	}
+	if teacher.ID == d.User.ID {
+		jsonError(w, "You can't be your own teacher", http.StatusBadRequest)
+		return
+	}
+
+	err = d.Users.Update(d.User.ID, func(u *models.User) error {
+		if !u.HasTeacher(teacher.ID) {
+			u.Teachers = append(u.Teachers, teacher.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		jsonError(w, "Failed to save", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]any{"success": true, "teacher": AccountInfo{ID: teacher.ID, Username: teacher.Username}})
+}
+
+// HandleRemoveTeacher revokes a teacher's access. Songs they already assigned stay.
+func (d *Deps) HandleRemoveTeacher(w http.ResponseWriter, r *http.Request) {
+	teacherID := r.PathValue("userId")
+
+	err := d.Users.Update(d.User.ID, func(u *models.User) error {
+		kept := u.Teachers[:0]
+		for _, id := range u.Teachers {
+			if id != teacherID {
+				kept = append(kept, id)
+			}
+		}
+		u.Teachers = kept
+		return nil
+	})
+	if err != nil {
+		jsonError(w, "Failed to save", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]any{"success": true})
+}
+
+// HandleListStudents returns the accounts that have added the current user as a teacher.
+func (d *Deps) HandleListStudents(w http.ResponseWriter, r *http.Request) {
+	students, err := d.Users.StudentsOf(d.User.ID)
+	if err != nil {
+		jsonError(w, "Failed to load students", http.StatusInternalServerError)
+		return
+	}
+	result := make([]AccountInfo, len(students))
+	for i, s := range students {
+		result[i] = AccountInfo{ID: s.ID, Username: s.Username}
+	}
+	jsonOK(w, result)
+}
+
+// AssignSongRequest is the JSON body for POST /api/songs/{songId}/assign.
+type AssignSongRequest struct {
+	StudentIDs []string `json:"studentIds"`
+}
+
+// AssignResult reports what happened for one student of an assignment.
+type AssignResult struct {
+	StudentID string `json:"studentId"`
+	Username  string `json:"username,omitempty"`
+	SongID    string `json:"songId,omitempty"` // the student's copy
+	Status    string `json:"status"`           // "assigned", "already_assigned", "not_a_student", "failed"
+}
+
+// HandleAssignSong copies a song into each student's library. Each copy starts
+// with fresh practice data and remembers which song it was assigned from.
+func (d *Deps) HandleAssignSong(w http.ResponseWriter, r *http.Request) {
+	songID := r.PathValue("songId")
+
+	var req AssignSongRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.StudentIDs) == 0 {
+		jsonError(w, "studentIds is required", http.StatusBadRequest)
+		return
+	}
+
+	song, err := d.Songs.Get(songID)
+	if err != nil {
+		jsonError(w, "Failed to load song", http.StatusInternalServerError)
+		return
+	}
+	if song == nil {
+		jsonError(w, "Song not found", http.StatusNotFound)
+		return
+	}
+
+	students, err := d.Users.StudentsOf(d.User.ID)
+	if err != nil {
+		jsonError(w, "Failed to load students", http.StatusInternalServerError)
+		return
+	}
+	byID := map[string]*models.User{}
+	for i := range students {
+		byID[students[i].ID] = &students[i]
+	}
+
+	results := make([]AssignResult, 0, len(req.StudentIDs))
+	for _, id := range req.StudentIDs {
+		student, ok := byID[id]
+		if !ok {
+			results = append(results, AssignResult{StudentID: id, Status: "not_a_student"})
+			continue
+		}
+		results = append(results, d.assignTo(r.Context(), student, song))
+	}
+
+	jsonOK(w, map[string]any{"success": true, "results": results})
+}
+
+// assignTo copies song into one student's library unless it is already there.
+func (d *Deps) assignTo(ctx context.Context, student *models.User, song *models.Song) AssignResult {
+	result := AssignResult{StudentID: student.ID, Username: student.Username}
+	lib := d.Libraries.For(student)
+
+	existing, err := lib.Songs.ListAll()
+	if err != nil {
+		slog.ErrorContext(ctx, "Assign: failed to list songs", "student", student.ID, "err", err)
+		result.Status = "failed"
+		return result
+	}
+	for _, s := range existing {
+		if s.Assignment != nil && s.Assignment.TeacherID == d.User.ID && s.Assignment.SourceSongID == song.ID {
+			result.SongID = s.ID
+			result.Status = "already_assigned"
+			return result
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	copied := *song
+	copied.ID = generateID()
+	copied.CreatedAt = now
+	copied.SharedReadOnly = false
+	copied.Assignment = &models.SongAssignment{
+		TeacherID:    d.User.ID,
+		TeacherName:  d.User.Username,
+		SourceSongID: song.ID,
+		AssignedAt:   now,
+	}
+	copied.Exercises = make([]models.Exercise, len(song.Exercises))
+	for i, ex := range song.Exercises {
+		ex.Stage = 1
+		ex.TotalPracticedSeconds = 0
+		ex.TotalReps = 0
+		ex.LastPracticedAt = nil
+		copied.Exercises[i] = ex
+	}
+
+	if err := d.Songs.CopyTo(lib.Songs, song.ID, &copied); err != nil {
+		slog.ErrorContext(ctx, "Assign: failed to copy song", "song", song.ID, "student", student.ID, "err", err)
+		result.Status = "failed"
+		return result
+	}
+	if d.Events != nil {
+		d.Events.Publish(student.ID, Event{Type: EventSong, SongID: copied.ID, Revision: copied.Revision})
+	}
+	result.SongID = copied.ID
+	result.Status = "assigned"
+	return result
+}
+
+// AssignedSongProgress is a student's progress on one song assigned by the teacher.
+type AssignedSongProgress struct {
+	SongID          string  `json:"songId"` // the student's copy
+	SourceSongID    string  `json:"sourceSongId"`
+	Title           string  `json:"title"`
+	Artist          string  `json:"artist"`
+	AssignedAt      string  `json:"assignedAt"`
+	ExerciseCount   int     `json:"exerciseCount"`
+	StageCounts     [5]int  `json:"stageCounts"`
+	PracticeMinutes int     `json:"practiceMinutes"`
+	WeekMinutes     int     `json:"weekMinutes"` // last 7 days, from the daily log
+	LastPracticedAt *string `json:"lastPracticedAt"`
+}
+
+// StudentProgress sums up one student's assigned songs.
+type StudentProgress struct {
+	ID              string                 `json:"id"`
+	Username        string                 `json:"username"`
+	Songs           []AssignedSongProgress `json:"songs"`
+	StageCounts     [5]int                 `json:"stageCounts"`
+	PracticeMinutes int                    `json:"practiceMinutes"`
+	WeekMinutes     int                    `json:"weekMinutes"`
+	LastPracticedAt *string                `json:"lastPracticedAt"`
+}
+
+// teachingDashboard collects progress for every student of the current user.
+// Only songs this teacher assigned are included.
+func (d *Deps) teachingDashboard(ctx context.Context, now time.Time) ([]StudentProgress, error) {
+	students, err := d.Users.StudentsOf(d.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := now.AddDate(0, 0, -6).Format("2006-01-02")
+	to := now.Format("2006-01-02")
+
+	progress := make([]StudentProgress, 0, len(students))
+	for i := range students {
+		student := &students[i]
+		lib := d.Libraries.For(student)
+		sp := StudentProgress{ID: student.ID, Username: student.Username, Songs: []AssignedSongProgress{}}
+
+		songs, err := lib.Songs.ListAll()
+		if err != nil {
+			slog.ErrorContext(ctx, "Teaching: failed to list songs", "student", student.ID, "err", err)
+		}
+		var practiceSeconds, weekSeconds int
+		for _, song := range songs {
+			if song.Assignment == nil || song.Assignment.TeacherID != d.User.ID {
+				continue
+			}
+			p := AssignedSongProgress{
+				SongID:          song.ID,
+				SourceSongID:    song.Assignment.SourceSongID,
+				Title:           song.Title,
+				Artist:          song.Artist,
+				AssignedAt:      song.Assignment.AssignedAt,
+				ExerciseCount:   len(song.Exercises),
+				LastPracticedAt: song.LastPracticed(),
+			}
+			var seconds int
+			for _, ex := range song.Exercises {
+				seconds += ex.TotalPracticedSeconds
+				if ex.Stage >= 1 && ex.Stage <= 5 {
+					p.StageCounts[ex.Stage-1]++
+					sp.StageCounts[ex.Stage-1]++
+				}
+			}
+			var week int
+			logs, _ := lib.DailyLogs.GetRange(song.ID, from, to)
+			for _, day := range logs {
+				for _, e := range day.Entries {
+					week += e.Seconds
+				}
+			}
+			p.PracticeMinutes = seconds / 60
+			p.WeekMinutes = week / 60
+			practiceSeconds += seconds
+			weekSeconds += week
+			if p.LastPracticedAt != nil && (sp.LastPracticedAt == nil || *p.LastPracticedAt > *sp.LastPracticedAt) {
+				sp.LastPracticedAt = p.LastPracticedAt
+			}
+			sp.Songs = append(sp.Songs, p)
+		}
+		sp.PracticeMinutes = practiceSeconds / 60
+		sp.WeekMinutes = weekSeconds / 60
+		progress = append(progress, sp)
+	}
+	return progress, nil
+}
+
+// HandleTeachingDashboard returns each student's progress as JSON.
+func (d *Deps) HandleTeachingDashboard(w http.ResponseWriter, r *http.Request) {
+	progress, err := d.teachingDashboard(r.Context(), time.Now().UTC())
+	if err != nil {
+		jsonError(w, "Failed to load students", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, progress)
+}
+
+// TeachingPageData is the template data for the teaching dashboard.
+type TeachingPageData struct {
+	Settings models.UserSettings
+	Username string
+	Students []StudentProgress
+}
+
+// HandleTeachingPage renders the teaching dashboard.
+func (d *Deps) HandleTeachingPage(w http.ResponseWriter, r *http.Request) {
+	progress, err := d.teachingDashboard(r.Context(), time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Failed to load students", http.StatusInternalServerError)
+		return
+	}
+	d.render(w, "teaching.html", TeachingPageData{
+		Settings: d.Settings.Get(),
+		Username: d.User.Username,
+		Students: progress,
+	})
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/LianHaeming/avoidnt/ai"
 	"github.com/LianHaeming/avoidnt/handlers"
+	"github.com/LianHaeming/avoidnt/prompts"
 	"github.com/LianHaeming/avoidnt/storage"
 	"github.com/LianHaeming/avoidnt/tmpl"
 )
@@ -29,75 +35,290 @@ func main() {
 	songsPath := envOr("SONGS_STORAGE_PATH", "data/songs")
 	settingsPath := envOr("SETTINGS_PATH", "data/settings.json")
 	pdfOutputPath := envOr("PDF_OUTPUT_PATH", "data/converted")
-	openaiKey := envOr("OPENAI_API_KEY", "")
+	aiKey := envOr("AI_API_KEY", envOr("OPENAI_API_KEY", ""))
+	aiBaseURL := envOr("AI_BASE_URL", "")
+	aiModel := envOr("AI_MODEL", "gpt-4o")
+	aiTimeout := envDuration("AI_TIMEOUT", "60s")
+	aiCachePath := envOr("AI_CACHE_PATH", "data/ai-cache")
+	aiDeadline := envDuration("AI_DEADLINE", "120s")
+	aiRetries, _ := strconv.Atoi(envOr("AI_MAX_RETRIES", "3"))
+	aiConcurrency, _ := strconv.Atoi(envOr("AI_CONCURRENCY", "2"))
+	aiUsagePath := envOr("AI_USAGE_PATH", "data/ai-usage.jsonl")
+	promptsPath := envOr("PROMPTS_PATH", "data/prompts")
+	ocrEngine := envOr("OCR_ENGINE", "auto")
+	ocrLang := envOr("OCR_LANG", "eng")
+	aiPriceInput, _ := strconv.ParseFloat(envOr("AI_PRICE_INPUT", "2.50"), 64)
+	aiPriceOutput, _ := strconv.ParseFloat(envOr("AI_PRICE_OUTPUT", "10.00"), 64)
+	aiDailyBudget, _ := strconv.ParseFloat(envOr("AI_DAILY_BUDGET", "0"), 64)
+	aiMonthlyBudget, _ := strconv.ParseFloat(envOr("AI_MONTHLY_BUDGET", "0"), 64)
+	previewWidths := envOr("PREVIEW_WIDTHS", "480,960,1600")
+	previewQuality, _ := strconv.Atoi(envOr("PREVIEW_QUALITY", "82"))
+	previewWebP := envOr("PREVIEW_WEBP", "auto")
+	previewWorkers, _ := strconv.Atoi(envOr("PREVIEW_WORKERS", "2"))
+	usersPath := envOr("USERS_PATH", "data/users.json")
+	sessionsPath := envOr("SESSIONS_PATH", "data/sessions.json")
+	tokensPath := envOr("TOKENS_PATH", "data/api-tokens.json")
+	shareSecretPath := envOr("SHARE_SECRET_PATH", "data/share-secret")
+	userDataPath := envOr("USER_DATA_PATH", "data/users")
+	sessionTTL := envDuration("SESSION_TTL", "720h")
+	allowRegistration := envOr("ALLOW_REGISTRATION", "true") == "true"
+	devAssetsDir := envOr("DEV_ASSETS_DIR", "")
+	logFormat := envOr("LOG_FORMAT", "text")
+	logLevel := envOr("LOG_LEVEL", "info")
+	readTimeout := envDuration("HTTP_READ_TIMEOUT", "2m")
+	writeTimeout := envDuration("HTTP_WRITE_TIMEOUT", "5m")
+	shutdownTimeout := envDuration("SHUTDOWN_TIMEOUT", "30s")
 
-	// Initialize storage
-	songStore := storage.NewSongStore(songsPath)
-	settingsStore := storage.NewSettingsStore(settingsPath)
+	// Structured logs; records logged with a request's context carry its ID.
+	// The standard log package writes through the same handler.
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	logOpts := &slog.HandlerOptions{Level: level}
+	var logHandler slog.Handler = slog.NewTextHandler(os.Stderr, logOpts)
+	if logFormat == "json" {
+		logHandler = slog.NewJSONHandler(os.Stderr, logOpts)
+	}
+	slog.SetDefault(slog.New(handlers.NewLogHandler(logHandler)))
+
+	// Initialize storage. Songs, settings and practice logs are per user; the
+	// first account keeps the library at SONGS_STORAGE_PATH / SETTINGS_PATH.
+	libraries := storage.NewLibraries(userDataPath, songsPath, settingsPath)
 	jobStore := storage.NewJobStore(pdfOutputPath)
-	dailyLogStore := storage.NewDailyLogStore(songsPath)
-	stageLogStore := storage.NewStageLogStore(songsPath)
+	shareKey, err := storage.LoadOrCreateSecret(shareSecretPath)
+	if err != nil {
+		log.Fatalf("Failed to load share link secret: %v", err)
+	}
+	aiCacheStore := storage.NewAICacheStore(aiCachePath)
+	aiUsage := &handlers.AIUsageMeter{
+		Store:         storage.NewAIUsageStore(aiUsagePath),
+		InputPrice:    aiPriceInput,
+		OutputPrice:   aiPriceOutput,
+		DailyBudget:   aiDailyBudget,
+		MonthlyBudget: aiMonthlyBudget,
+	}
+
+	// AI provider: OpenAI by default, or any OpenAI-compatible server via AI_BASE_URL.
+	// Requests wait for one of AI_CONCURRENCY slots, then retry transient failures.
+	// Every request is checked against the budgets and logged with its cost.
+	var aiProvider ai.Provider
+	if aiKey != "" || aiBaseURL != "" {
+		aiProvider = ai.NewOpenAI(ai.Config{
+			BaseURL: aiBaseURL,
+			Model:   aiModel,
+			APIKey:  aiKey,
+			Timeout: aiTimeout,
+		})
+		aiProvider = ai.WithConcurrencyLimit(aiProvider, aiConcurrency)
+		aiProvider = ai.WithRetry(aiProvider, ai.RetryPolicy{
+			MaxAttempts: aiRetries + 1,
+			BaseDelay:   time.Second,
+			MaxDelay:    20 * time.Second,
+			Deadline:    aiDeadline,
+		})
+		aiProvider = ai.WithMeter(aiProvider, aiUsage)
+	}
 
-	// Parse templates
-	templates := tmpl.Load(assetVer)
+	// Templates and static files are embedded; DEV_ASSETS_DIR reads them from
+	// disk instead and re-parses templates when they change.
+	templatesFS, staticFS := assetFS(devAssetsDir)
+	loadTemplates := tmpl.Load
+	if devAssetsDir != "" {
+		loadTemplates = tmpl.LoadLive
+		log.Printf("Serving templates and static files from %s", devAssetsDir)
+	}
+	templates, err := loadTemplates(templatesFS, assetVer)
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
 
 	// Build handler dependencies
 	deps := &handlers.Deps{
-		Songs:     songStore,
-		Settings:  settingsStore,
-		Jobs:      jobStore,
-		DailyLogs: dailyLogStore,
-		StageLogs: stageLogStore,
-		Templates: templates,
-		OpenAIKey: openaiKey,
-		PdfOutput: pdfOutputPath,
+		Jobs:              jobStore,
+		Templates:         templates,
+		Static:            staticFS,
+		AI:                aiProvider,
+		AICache:           aiCacheStore,
+		AIUsage:           aiUsage,
+		Prompts:           prompts.New(promptsPath),
+		OCR:               handlers.NewOCR(ocrEngine, ocrLang),
+		PdfOutput:         pdfOutputPath,
+		Previews:          handlers.NewPreviewOptions(previewWidths, previewQuality, previewWebP),
+		PreviewJobs:       handlers.NewPreviewJobs(previewWorkers),
+		Events:            handlers.NewEventBus(),
+		Users:             storage.NewUserStore(usersPath),
+		Sessions:          storage.NewSessionStore(sessionsPath),
+		Tokens:            storage.NewTokenStore(tokensPath),
+		Libraries:         libraries,
+		SessionTTL:        sessionTTL,
+		AllowRegistration: allowRegistration,
+		ShareKey:          shareKey,
 	}
 
 	// Routes
 	mux := http.NewServeMux()
 
 	// Static files
-	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	static := http.StripPrefix("/static/", http.FileServerFS(staticFS))
+	if devAssetsDir != "" {
+		static = noCache(static)
+	}
+	mux.Handle("GET /static/", static)
+	mux.HandleFunc("GET /sw.js", deps.HandleServiceWorker)
+
+	// Accounts (public)
+	mux.HandleFunc("GET /login", deps.HandleLoginPage)
+	mux.HandleFunc("POST /login", deps.HandleLogin)
+	mux.HandleFunc("GET /register", deps.HandleRegisterPage)
+	mux.HandleFunc("POST /register", deps.HandleRegister)
+	mux.HandleFunc("POST /logout", deps.HandleLogout)
+	mux.HandleFunc("GET /api/v1/openapi.json", deps.HandleOpenAPI)
+
+	// Public share links (the signed token is the credential)
+	mux.HandleFunc("GET /shared/{token}", deps.HandlePublicSong)
+	mux.HandleFunc("GET /shared/{token}/preview/{cropId}", deps.HandlePublicPreview)
+
+	// Everything below runs against the signed-in user's library.
 
 	// Pages (return full HTML)
-	mux.HandleFunc("GET /", deps.HandleHome)
-	mux.HandleFunc("GET /songs", deps.HandleSongsList)
-	mux.HandleFunc("GET /songs/new", deps.HandlePlanDesignerNew)
-	mux.HandleFunc("GET /songs/{songId}", deps.HandleSongDetail)
-	mux.HandleFunc("GET /songs/{songId}/edit", deps.HandleSongDetailEdit)
-	mux.HandleFunc("GET /settings", deps.HandleSettingsPage)
+	mux.HandleFunc("GET /", deps.Scoped((*handlers.Deps).HandleHome))
+	mux.HandleFunc("GET /songs", deps.Scoped((*handlers.Deps).HandleSongsList))
+	mux.HandleFunc("GET /songs/new", deps.Scoped((*handlers.Deps).HandlePlanDesignerNew))
+	mux.HandleFunc("GET /songs/{songId}", deps.Scoped((*handlers.Deps).HandleSongDetail))
+	mux.HandleFunc("GET /songs/{songId}/edit", deps.Scoped((*handlers.Deps).HandleSongDetailEdit))
+	mux.HandleFunc("GET /settings", deps.Scoped((*handlers.Deps).HandleSettingsPage))
 
 	// htmx partials + API endpoints
-	mux.HandleFunc("GET /api/songs", deps.HandleSongsListPartial)
-	mux.HandleFunc("POST /api/songs", deps.HandleSaveSong)
-	mux.HandleFunc("DELETE /api/songs/{songId}", deps.HandleDeleteSong)
-	mux.HandleFunc("PATCH /api/songs/{songId}/exercises/{exerciseId}", deps.HandlePatchExercise)
-	mux.HandleFunc("PATCH /api/songs/{songId}/display", deps.HandlePatchSongDisplay)
-	mux.HandleFunc("POST /api/songs/{songId}/regenerate-previews", deps.HandleRegeneratePreviews)
-	mux.HandleFunc("GET /api/songs/{songId}/preview/{cropId}", deps.HandlePreview)
+	mux.HandleFunc("GET /api/songs", deps.Scoped((*handlers.Deps).HandleSongsListPartial))
+	mux.HandleFunc("POST /api/songs", deps.Scoped((*handlers.Deps).HandleSaveSong))
+	mux.HandleFunc("DELETE /api/songs/{songId}", deps.Scoped((*handlers.Deps).HandleDeleteSong))
+	mux.HandleFunc("PATCH /api/songs/{songId}/exercises/{exerciseId}", deps.Scoped((*handlers.Deps).HandlePatchExercise))
+	mux.HandleFunc("POST /api/songs/{songId}/exercises/{exerciseId}/practice", deps.Scoped((*handlers.Deps).HandleRecordPractice))
+	mux.HandleFunc("PATCH /api/songs/{songId}/display", deps.Scoped((*handlers.Deps).HandlePatchSongDisplay))
+	mux.HandleFunc("POST /api/songs/{songId}/regenerate-previews", deps.Scoped((*handlers.Deps).HandleRegeneratePreviews))
+	mux.HandleFunc("POST /api/previews/regenerate", deps.Scoped((*handlers.Deps).HandleRegenerateAllPreviews))
+	mux.HandleFunc("GET /api/preview-jobs/{jobId}", deps.Scoped((*handlers.Deps).HandleGetPreviewJob))
+	mux.HandleFunc("GET /api/songs/{songId}/preview/{cropId}", deps.Scoped((*handlers.Deps).HandlePreview))
+	mux.HandleFunc("GET /api/songs/{songId}/exercises/{exerciseId}/image", deps.Scoped((*handlers.Deps).HandleExerciseImage))
+	mux.HandleFunc("GET /api/songs/{songId}/export.pdf", deps.Scoped((*handlers.Deps).HandleExportSongPDF))
+	mux.HandleFunc("GET /api/export.pdf", deps.Scoped((*handlers.Deps).HandleExportSetlistPDF))
 
 	// Daily log & stage log
-	mux.HandleFunc("GET /api/songs/{songId}/daily-log", deps.HandleGetDailyLog)
-	mux.HandleFunc("PATCH /api/songs/{songId}/daily-log", deps.HandlePatchDailyLog)
-	mux.HandleFunc("GET /api/songs/{songId}/stage-log", deps.HandleGetStageLog)
-	mux.HandleFunc("POST /api/songs/{songId}/transitions", deps.HandleToggleTransition)
+	mux.HandleFunc("GET /api/songs/{songId}/daily-log", deps.Scoped((*handlers.Deps).HandleGetDailyLog))
+	mux.HandleFunc("PATCH /api/songs/{songId}/daily-log", deps.Scoped((*handlers.Deps).HandlePatchDailyLog))
+	mux.HandleFunc("GET /api/songs/{songId}/stage-log", deps.Scoped((*handlers.Deps).HandleGetStageLog))
+	mux.HandleFunc("POST /api/songs/{songId}/transitions", deps.Scoped((*handlers.Deps).HandleToggleTransition))
+
+	// Offline sync queue
+	mux.HandleFunc("POST /api/sync", deps.Scoped((*handlers.Deps).HandleSync))
 
+	// Sharing & teaching
+	mux.HandleFunc("GET /teaching", deps.Scoped((*handlers.Deps).HandleTeachingPage))
+	mux.HandleFunc("GET /users/{userId}/songs/{songId}", deps.Scoped((*handlers.Deps).HandleSharedSong))
+	mux.HandleFunc("GET /users/{userId}/songs/{songId}/preview/{cropId}", deps.Scoped((*handlers.Deps).HandleSharedPreview))
+	mux.HandleFunc("PUT /api/songs/{songId}/share", deps.Scoped((*handlers.Deps).HandleShareSong))
+	mux.HandleFunc("POST /api/songs/{songId}/assign", deps.Scoped((*handlers.Deps).HandleAssignSong))
+	mux.HandleFunc("GET /api/songs/{songId}/share-links", deps.Scoped((*handlers.Deps).HandleListShareLinks))
+	mux.HandleFunc("POST /api/songs/{songId}/share-links", deps.Scoped((*handlers.Deps).HandleCreateShareLink))
+	mux.HandleFunc("DELETE /api/songs/{songId}/share-links/{linkId}", deps.Scoped((*handlers.Deps).HandleDeleteShareLink))
+	mux.HandleFunc("GET /api/teachers", deps.Scoped((*handlers.Deps).HandleListTeachers))
+	mux.HandleFunc("POST /api/teachers", deps.Scoped((*handlers.Deps).HandleAddTeacher))
+	mux.HandleFunc("DELETE /api/teachers/{userId}", deps.Scoped((*handlers.Deps).HandleRemoveTeacher))
+	mux.HandleFunc("GET /api/students", deps.Scoped((*handlers.Deps).HandleListStudents))
+	mux.HandleFunc("GET /api/teaching", deps.Scoped((*handlers.Deps).HandleTeachingDashboard))
+
+	// Live updates (Server-Sent Events)
+	mux.HandleFunc("GET /api/events", deps.Scoped((*handlers.Deps).HandleEvents))
 
 	// Settings
-	mux.HandleFunc("GET /api/settings", deps.HandleGetSettings)
-	mux.HandleFunc("PUT /api/settings", deps.HandleUpdateSettings)
+	mux.HandleFunc("GET /api/settings", deps.Scoped((*handlers.Deps).HandleGetSettings))
+	mux.HandleFunc("PUT /api/settings", deps.Scoped((*handlers.Deps).HandleUpdateSettings))
 
 	// PDF conversion
-	mux.HandleFunc("POST /api/convert", deps.HandleConvertPDF)
-	mux.HandleFunc("GET /api/pages/{jobId}/{pageNum}", deps.HandleGetPage)
+	mux.HandleFunc("POST /api/convert", deps.Scoped((*handlers.Deps).HandleConvertPDF))
+	mux.HandleFunc("GET /api/pages/{jobId}/{pageNum}", deps.Scoped((*handlers.Deps).HandleGetPage))
 
 	// AI analyze
-	mux.HandleFunc("POST /api/analyze-pdf", deps.HandleAnalyzePDF)
-	mux.HandleFunc("POST /api/label-exercises", deps.HandleLabelExercises)
+	mux.HandleFunc("POST /api/analyze-pdf", deps.Scoped((*handlers.Deps).HandleAnalyzePDF))
+	mux.HandleFunc("POST /api/label-exercises", deps.Scoped((*handlers.Deps).HandleLabelExercises))
+	mux.HandleFunc("POST /api/propose-exercises", deps.Scoped((*handlers.Deps).HandleProposeExercises))
+	mux.HandleFunc("GET /api/ai/cache", deps.Scoped((*handlers.Deps).HandleListAICache))
+	mux.HandleFunc("DELETE /api/ai/cache", deps.Scoped((*handlers.Deps).HandleClearAICache))
+	mux.HandleFunc("DELETE /api/ai/cache/{key}", deps.Scoped((*handlers.Deps).HandleDeleteAICacheEntry))
+	mux.HandleFunc("GET /api/ai/usage", deps.Scoped((*handlers.Deps).HandleAIUsage))
+	mux.HandleFunc("POST /api/songs/{songId}/coach", deps.Scoped((*handlers.Deps).HandleCoach))
+	mux.HandleFunc("GET /api/songs/{songId}/coach", deps.Scoped((*handlers.Deps).HandleListCoachReports))
+	mux.HandleFunc("GET /api/prompts", deps.Scoped((*handlers.Deps).HandleListPrompts))
+	mux.HandleFunc("GET /api/prompts/{name}/preview", deps.Scoped((*handlers.Deps).HandlePreviewPrompt))
+
+	// Personal API tokens
+	mux.HandleFunc("GET /api/tokens", deps.Scoped((*handlers.Deps).HandleListTokens))
+	mux.HandleFunc("POST /api/tokens", deps.Scoped((*handlers.Deps).HandleCreateToken))
+	mux.HandleFunc("DELETE /api/tokens/{tokenId}", deps.Scoped((*handlers.Deps).HandleDeleteToken))
 
-	addr := fmt.Sprintf(":%s", port)
-	log.Printf("Avoidnt listening on http://localhost:%s", port)
-	log.Fatal(http.ListenAndServe(addr, mux))
+	// Versioned JSON API (bearer token or session). Documented in
+	// handlers/openapi.json; keep the two in sync.
+	mux.HandleFunc("GET /api/v1/me", deps.Scoped((*handlers.Deps).HandleV1Me))
+	mux.HandleFunc("GET /api/v1/songs", deps.Scoped((*handlers.Deps).HandleV1ListSongs))
+	mux.HandleFunc("POST /api/v1/songs", deps.Scoped((*handlers.Deps).HandleSaveSong))
+	mux.HandleFunc("GET /api/v1/songs/{songId}", deps.Scoped((*handlers.Deps).HandleV1GetSong))
+	mux.HandleFunc("DELETE /api/v1/songs/{songId}", deps.Scoped((*handlers.Deps).HandleDeleteSong))
+	mux.HandleFunc("GET /api/v1/songs/{songId}/exercises", deps.Scoped((*handlers.Deps).HandleV1ListExercises))
+	mux.HandleFunc("PATCH /api/v1/songs/{songId}/exercises/{exerciseId}", deps.Scoped((*handlers.Deps).HandlePatchExercise))
+	mux.HandleFunc("POST /api/v1/songs/{songId}/exercises/{exerciseId}/practice", deps.Scoped((*handlers.Deps).HandleRecordPractice))
+	mux.HandleFunc("GET /api/v1/songs/{songId}/daily-log", deps.Scoped((*handlers.Deps).HandleGetDailyLog))
+	mux.HandleFunc("PATCH /api/v1/songs/{songId}/daily-log", deps.Scoped((*handlers.Deps).HandlePatchDailyLog))
+	mux.HandleFunc("GET /api/v1/songs/{songId}/stage-log", deps.Scoped((*handlers.Deps).HandleGetStageLog))
+	mux.HandleFunc("GET /api/v1/settings", deps.Scoped((*handlers.Deps).HandleGetSettings))
+	mux.HandleFunc("PUT /api/v1/settings", deps.Scoped((*handlers.Deps).HandleUpdateSettings))
+	mux.HandleFunc("POST /api/v1/jobs", deps.Scoped((*handlers.Deps).HandleConvertPDF))
+	mux.HandleFunc("GET /api/v1/jobs/{jobId}", deps.Scoped((*handlers.Deps).HandleV1GetJob))
+	mux.HandleFunc("GET /api/v1/jobs/{jobId}/pages/{pageNum}", deps.Scoped((*handlers.Deps).HandleGetPage))
+
+	// PDF conversion and AI analysis run inside the request, so the write
+	// timeout is generous; the event stream clears its own deadline.
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           handlers.AccessLog(deps.RequireAuth(mux)),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       2 * time.Minute,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	srv.RegisterOnShutdown(deps.Events.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		slog.Info("Avoidnt listening on http://localhost:" + port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// On SIGTERM (redeploy), stop accepting connections and let in-flight
+	// requests and preview jobs finish. A second signal exits immediately.
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Requests still running at shutdown timeout", "err", err)
+	}
+	jobsDone := make(chan struct{})
+	go func() {
+		deps.PreviewJobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+		slog.Info("Shutdown complete")
+	case <-shutdownCtx.Done():
+		slog.Error("Preview jobs still running at shutdown timeout")
+	}
 }
 
 func envOr(key, fallback string) string {
@@ -106,3 +327,24 @@ func envOr(key, fallback string) string {
 	}
 	return fallback
 }
+
+// envDuration parses a duration variable such as "90s" or "720h", falling
+// back to def when unset. A typo must not quietly become a zero timeout, so
+// a value that doesn't parse or isn't positive stops startup.
+func envDuration(key, def string) time.Duration {
+	v := envOr(key, def)
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s=%q: want a positive duration such as 30s, 5m or 720h", key, v)
+	}
+	return d
+}
+
+// noCache makes browsers revalidate every response, so edited static files
+// show up on reload during development.
+func noCache(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		h.ServeHTTP(w, r)
+	})
+}
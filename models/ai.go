@@ -0,0 +1,43 @@
+package models
+
+import "encoding/json"
+
+// AICacheEntry is a stored AI result, reused for identical repeat requests.
+type AICacheEntry struct {
+	Key        string          `json:"key"`
+	UserID     string          `json:"userId"` // who asked; entries are private to them
+	Kind       string          `json:"kind"`   // "analyze", "label", "propose"
+	JobID      string          `json:"jobId,omitempty"`
+	PageHashes []string        `json:"pageHashes"`
+	Model      string          `json:"model"`
+	CreatedAt  string          `json:"createdAt"` // ISO 8601
+	Result     json.RawMessage `json:"result"`
+}
+
+// AIUsageEntry records one AI request with its token usage and estimated cost.
+type AIUsageEntry struct {
+	Time             string  `json:"time"`     // ISO 8601
+	Endpoint         string  `json:"endpoint"` // "analyze", "label", "propose"
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	CostUSD          float64 `json:"costUsd"`
+}
+
+// CoachReport is AI practice advice for a song, kept so it can be reviewed later.
+type CoachReport struct {
+	ID          string            `json:"id"`
+	CreatedAt   string            `json:"createdAt"` // ISO 8601
+	Model       string            `json:"model"`
+	Summary     string            `json:"summary"`
+	Suggestions []CoachSuggestion `json:"suggestions"`
+}
+
+// CoachSuggestion is one concrete recommendation, optionally about a single exercise.
+type CoachSuggestion struct {
+	ExerciseID string `json:"exerciseId,omitempty"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	Priority   string `json:"priority"` // "high", "medium", "low"
+}
@@ -0,0 +1,32 @@
+package models
+
+import "regexp"
+
+// idPattern is the shape of song, exercise and crop IDs: server-generated hex
+// or client UUIDs. IDs become file and directory names, so anything else
+// (dots, slashes) is refused before it gets near the filesystem.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidID reports whether id is safe to use as a song, exercise or crop ID.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// InvalidSongID returns the first song, exercise or crop ID in song that is
+// not a ValidID, or "" when all are valid.
+func InvalidSongID(song *Song) string {
+	if !ValidID(song.ID) {
+		return song.ID
+	}
+	for _, ex := range song.Exercises {
+		if !ValidID(ex.ID) {
+			return ex.ID
+		}
+		for _, c := range ex.Crops {
+			if !ValidID(c.ID) {
+				return c.ID
+			}
+		}
+	}
+	return ""
+}
@@ -13,7 +13,7 @@ var DefaultStageNames = [5]string{
 type UserSettings struct {
 	Theme       string   `json:"theme"`
 	StageNames  []string `json:"stageNames"`
-	DisplayName string   `json:"displayName"`
+	DisplayName string   `json:"displayName"` // empty means "use the username"
 }
 
 // DefaultSettings returns settings with default values.
@@ -21,8 +21,7 @@ func DefaultSettings() UserSettings {
 	names := make([]string, 5)
 	copy(names, DefaultStageNames[:])
 	return UserSettings{
-		Theme:       "light",
-		StageNames:  names,
-		DisplayName: "Lian",
+		Theme:      "light",
+		StageNames: names,
 	}
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ShareLink is a public, revocable link to a song's read-only view. The link
+// itself is signed by the server; only its ID and bookkeeping are stored.
+type ShareLink struct {
+	ID             string  `json:"id"`
+	CreatedAt      string  `json:"createdAt"`      // ISO 8601
+	ExpiresAt      *string `json:"expiresAt"`      // ISO 8601, nil = never
+	AccessCount    int     `json:"accessCount"`    // page views, previews excluded
+	LastAccessedAt *string `json:"lastAccessedAt"` // ISO 8601
+}
+
+// Expired reports whether the link has passed its expiry time.
+func (l ShareLink) Expired(now time.Time) bool {
+	if l.ExpiresAt == nil {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, *l.ExpiresAt)
+	return err == nil && !now.Before(t)
+}
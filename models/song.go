@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 // Rect represents normalized crop coordinates (0-1).
 type Rect struct {
 	X float64 `json:"x"`
@@ -14,6 +16,7 @@ type Crop struct {
 	PageIndex     int     `json:"pageIndex"`
 	Rect          Rect    `json:"rect"`
 	PreviewBase64 *string `json:"previewBase64,omitempty"`
+	PreviewHash   string  `json:"previewHash,omitempty"` // content hash of preview_{id}.png
 }
 
 // Section is a song structure element (e.g. Intro, Verse, Chorus).
@@ -49,6 +52,11 @@ type Song struct {
 	Title       string     `json:"title"`
 	Artist      string     `json:"artist"`
 	Tempo       *float64   `json:"tempo"`
+	Key         *string    `json:"key,omitempty"`           // tonic, e.g. "E", "F#", "Bb"
+	Mode        *string    `json:"mode,omitempty"`          // "major", "minor", "dorian", ...
+	TimeSignature *string  `json:"timeSignature,omitempty"` // e.g. "4/4", "6/8"
+	Capo        *int       `json:"capo,omitempty"`          // fret; nil = no capo
+	Tuning      *string    `json:"tuning,omitempty"`        // e.g. "Standard", "Drop D", "DADGAD"
 	YoutubeURL  *string    `json:"youtubeUrl"`
 	SpotifyURL  *string    `json:"spotifyUrl"`
 	JobID       string     `json:"jobId"`
@@ -62,6 +70,25 @@ type Song struct {
 	HideDividers bool    `json:"hideDividers,omitempty"`
 	HideStages   bool    `json:"hideStages,omitempty"`
 	HideCards    bool    `json:"hideCards,omitempty"`
+	// SharedReadOnly lets any signed-in account open the song's read-only view.
+	SharedReadOnly bool            `json:"sharedReadOnly,omitempty"`
+	Assignment     *SongAssignment `json:"assignment,omitempty"` // set on copies assigned by a teacher
+	// Revision is bumped by the store on every write; clients send it back in
+	// If-Match to detect concurrent edits.
+	Revision int `json:"revision"`
+}
+
+// ETag is the song's revision as an HTTP entity tag.
+func (s Song) ETag() string {
+	return fmt.Sprintf(`"%d"`, s.Revision)
+}
+
+// SongAssignment records where an assigned song came from.
+type SongAssignment struct {
+	TeacherID    string `json:"teacherId"`
+	TeacherName  string `json:"teacherName"`
+	SourceSongID string `json:"sourceSongId"` // the song in the teacher's library
+	AssignedAt   string `json:"assignedAt"`   // ISO 8601
 }
 
 // SongSummary is used for the browse/list view.
@@ -95,6 +122,16 @@ func (s *Song) LowestStage() *int {
 	return &min
 }
 
+// FindExercise returns the exercise with the given ID, or nil.
+func (s *Song) FindExercise(id string) *Exercise {
+	for i := range s.Exercises {
+		if s.Exercises[i].ID == id {
+			return &s.Exercises[i]
+		}
+	}
+	return nil
+}
+
 // LastPracticed returns the most recent lastPracticedAt across exercises.
 func (s *Song) LastPracticed() *string {
 	var latest *string
@@ -108,6 +145,36 @@ func (s *Song) LastPracticed() *string {
 	return latest
 }
 
+// KeyLabel returns the key with its mode, e.g. "E minor", or "" if unknown.
+func (s Song) KeyLabel() string {
+	if s.Key == nil {
+		return ""
+	}
+	if s.Mode != nil {
+		return *s.Key + " " + *s.Mode
+	}
+	return *s.Key
+}
+
+// MusicLabels returns short labels for the key, time signature, capo and
+// tuning that are set, in display order.
+func (s Song) MusicLabels() []string {
+	var labels []string
+	if k := s.KeyLabel(); k != "" {
+		labels = append(labels, k)
+	}
+	if s.TimeSignature != nil {
+		labels = append(labels, *s.TimeSignature)
+	}
+	if s.Capo != nil && *s.Capo > 0 {
+		labels = append(labels, fmt.Sprintf("Capo %d", *s.Capo))
+	}
+	if s.Tuning != nil {
+		labels = append(labels, *s.Tuning)
+	}
+	return labels
+}
+
 // ToSummary converts a Song to a SongSummary.
 func (s *Song) ToSummary() SongSummary {
 	mastered := 0
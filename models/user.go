@@ -0,0 +1,45 @@
+package models
+
+// User is an account with its own song library and settings.
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"passwordHash"`
+	CreatedAt    string `json:"createdAt"` // ISO 8601
+	// Legacy marks the first account, which owns the library that existed
+	// before accounts were introduced (SONGS_STORAGE_PATH, SETTINGS_PATH).
+	Legacy bool `json:"legacy,omitempty"`
+	// Teachers are accounts this user allows to assign songs to them and to
+	// see their progress on assigned songs.
+	Teachers []string `json:"teachers,omitempty"`
+}
+
+// HasTeacher reports whether the account with the given ID is one of u's teachers.
+func (u *User) HasTeacher(id string) bool {
+	for _, t := range u.Teachers {
+		if t == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is a signed-in browser. Only a hash of the cookie token is stored.
+type Session struct {
+	TokenHash string `json:"tokenHash"`
+	UserID    string `json:"userId"`
+	CreatedAt string `json:"createdAt"` // ISO 8601
+	ExpiresAt string `json:"expiresAt"` // ISO 8601
+}
+
+// APIToken is a personal bearer token for the /api/v1 JSON API. Only a hash
+// of the token is stored; Prefix is kept so users can tell tokens apart.
+type APIToken struct {
+	ID         string  `json:"id"`
+	UserID     string  `json:"userId"`
+	Name       string  `json:"name"`
+	Prefix     string  `json:"prefix"`
+	TokenHash  string  `json:"tokenHash"`
+	CreatedAt  string  `json:"createdAt"`  // ISO 8601
+	LastUsedAt *string `json:"lastUsedAt"` // ISO 8601, updated at most hourly
+}
@@ -0,0 +1,330 @@
+// Package pdfgen writes simple PDF documents (text, JPEG images, rectangles
+// and URL links) using only the standard library. It covers what the
+// practice sheet export needs and nothing more: the two built-in Helvetica
+// faces, WinAnsi text and DCT-encoded images.
+package pdfgen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// Standard page sizes in points (1/72 inch).
+var (
+	A4     = Size{595.28, 841.89}
+	Letter = Size{612, 792}
+)
+
+// Size is a page size in points.
+type Size struct {
+	W float64
+	H float64
+}
+
+// Font selects one of the built-in fonts.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+// Document is an in-memory PDF being built page by page.
+type Document struct {
+	size   Size
+	pages  []*Page
+	images []*Image
+}
+
+// Page is a single page. Coordinates have their origin at the top-left
+// corner with y growing downwards; they are flipped when written.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+	links   []link
+	images  map[int]bool
+}
+
+// Image is a JPEG image embedded once and drawable on any page.
+type Image struct {
+	id     int
+	data   []byte
+	Width  int
+	Height int
+}
+
+type link struct {
+	x, y, w, h float64
+	url        string
+}
+
+// New creates an empty document with the given page size.
+func New(size Size) *Document {
+	return &Document{size: size}
+}
+
+// Size returns the document's page size.
+func (d *Document) Size() Size {
+	return d.size
+}
+
+// AddPage appends a blank page and returns it.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d, images: map[int]bool{}}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// AddImage encodes img as a JPEG (flattened onto white) and embeds it.
+func (d *Document) AddImage(img image.Image, quality int) (*Image, error) {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg: %w", err)
+	}
+	im := &Image{id: len(d.images), data: buf.Bytes(), Width: b.Dx(), Height: b.Dy()}
+	d.images = append(d.images, im)
+	return im, nil
+}
+
+// Text draws s with its baseline at (x, y).
+func (p *Page) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n",
+		font+1, size, x, p.doc.size.H-y, escape(encodeWinAnsi(s)))
+}
+
+// TextColor sets the fill color used for subsequent text and rectangles.
+func (p *Page) TextColor(c color.Color) {
+	r, g, b, _ := c.RGBA()
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg\n", float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+}
+
+// FillRect fills a rectangle whose top-left corner is (x, y).
+func (p *Page) FillRect(x, y, w, h float64, c color.Color) {
+	r, g, b, _ := c.RGBA()
+	fmt.Fprintf(&p.content, "q %.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f Q\n",
+		float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff, x, p.doc.size.H-y-h, w, h)
+}
+
+// DrawImage draws img into the box whose top-left corner is (x, y).
+func (p *Page) DrawImage(img *Image, x, y, w, h float64) {
+	p.images[img.id] = true
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", w, h, x, p.doc.size.H-y-h, img.id)
+}
+
+// Link makes the box whose top-left corner is (x, y) open url when clicked.
+func (p *Page) Link(x, y, w, h float64, url string) {
+	p.links = append(p.links, link{x, y, w, h, url})
+}
+
+// TextWidth returns the width of s in points when set in font at size.
+func TextWidth(s string, font Font, size float64) float64 {
+	widths := &helveticaWidths
+	if font == HelveticaBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, b := range encodeWinAnsi(s) {
+		if b >= 32 && b <= 126 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Truncate shortens s with an ellipsis so it fits within maxWidth.
+func Truncate(s string, font Font, size, maxWidth float64) string {
+	if TextWidth(s, font, size) <= maxWidth {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 {
+		r = r[:len(r)-1]
+		candidate := strings.TrimSpace(string(r)) + "…"
+		if TextWidth(candidate, font, size) <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// WriteTo serializes the document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+
+	// Object numbering: 1 catalog, 2 pages tree, 3-4 fonts, then images,
+	// then for each page: page, content stream, one object per link.
+	imageBase := 5
+	pageBase := imageBase + len(d.images)
+	pageObj := make([]int, len(d.pages))
+	next := pageBase
+	for i, p := range d.pages {
+		pageObj[i] = next
+		next += 2 + len(p.links)
+	}
+
+	begin := func(n int) {
+		for len(offsets) < n {
+			offsets = append(offsets, 0)
+		}
+		offsets[n-1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", n)
+	}
+	end := func() { buf.WriteString("endobj\n") }
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	begin(1)
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\n")
+	end()
+
+	begin(2)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj[i])
+	}
+	fmt.Fprintf(&buf, "<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>\n",
+		strings.Join(kids, " "), len(d.pages), d.size.W, d.size.H)
+	end()
+
+	begin(3)
+	buf.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\n")
+	end()
+	begin(4)
+	buf.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>\n")
+	end()
+
+	for _, im := range d.images {
+		begin(imageBase + im.id)
+		fmt.Fprintf(&buf, "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n",
+			im.Width, im.Height, len(im.data))
+		buf.Write(im.data)
+		buf.WriteString("\nendstream\n")
+		end()
+	}
+
+	for i, p := range d.pages {
+		obj := pageObj[i]
+
+		var xobjs []string
+		for id := range d.images {
+			if p.images[id] {
+				xobjs = append(xobjs, fmt.Sprintf("/Im%d %d 0 R", id, imageBase+id))
+			}
+		}
+		var annots []string
+		for j := range p.links {
+			annots = append(annots, fmt.Sprintf("%d 0 R", obj+2+j))
+		}
+
+		begin(obj)
+		fmt.Fprintf(&buf, "<< /Type /Page /Parent 2 0 R /Contents %d 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s >> >>",
+			obj+1, strings.Join(xobjs, " "))
+		if len(annots) > 0 {
+			fmt.Fprintf(&buf, " /Annots [%s]", strings.Join(annots, " "))
+		}
+		buf.WriteString(" >>\n")
+		end()
+
+		begin(obj + 1)
+		fmt.Fprintf(&buf, "<< /Length %d >>\nstream\n", p.content.Len())
+		buf.Write(p.content.Bytes())
+		buf.WriteString("endstream\n")
+		end()
+
+		for j, l := range p.links {
+			begin(obj + 2 + j)
+			fmt.Fprintf(&buf, "<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /A << /S /URI /URI (%s) >> >>\n",
+				l.x, d.size.H-l.y-l.h, l.x+l.w, d.size.H-l.y, escape([]byte(l.url)))
+			end()
+		}
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// escape backslash-escapes the characters that are special in PDF strings.
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '\\', '(', ')':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n', '\r':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// winAnsiExtras maps the non-Latin-1 runes WinAnsiEncoding can represent.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'‰': 0x89, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encodeWinAnsi converts s to WinAnsiEncoding, replacing unsupported runes.
+func encodeWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			out = append(out, byte(r))
+		case r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		case r == '→':
+			out = append(out, '-', '>')
+		default:
+			if b, ok := winAnsiExtras[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// Glyph widths for ASCII 32-126, in 1/1000 em, from the standard AFM files.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,122 @@
+// Package prompts renders the AI prompt templates. Defaults are embedded in
+// the binary; a file with the same name in the override directory replaces
+// one, and is re-read on every render so prompt edits apply immediately.
+//
+// Each AI call uses two text/template files: "<name>.system.tmpl" and
+// "<name>.user.tmpl", executed with call-specific data.
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed *.tmpl
+var defaults embed.FS
+
+// Store loads prompt templates from an override directory, falling back to
+// the embedded defaults.
+type Store struct {
+	dir string
+}
+
+// New creates a store reading overrides from dir (may be empty or missing).
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Source is where a template was loaded from: "override" or "default".
+type Source string
+
+const (
+	SourceOverride Source = "override"
+	SourceDefault  Source = "default"
+)
+
+// Template describes one prompt template file.
+type Template struct {
+	Name   string `json:"name"` // e.g. "label.system"
+	Source Source `json:"source"`
+}
+
+// Render executes the template "<name>.tmpl" with data.
+func (s *Store) Render(name string, data any) (string, Source, error) {
+	text, src, err := s.load(name)
+	if err != nil {
+		return "", "", err
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", "", fmt.Errorf("prompt %s (%s): %w", name, src, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("prompt %s (%s): %w", name, src, err)
+	}
+	return strings.TrimSpace(buf.String()), src, nil
+}
+
+// Version returns a short hash of the named templates' current text, so
+// results derived from a prompt can be invalidated when it changes.
+// Unknown names are ignored.
+func (s *Store) Version(names ...string) string {
+	h := sha256.New()
+	for _, name := range names {
+		text, _, _ := s.load(name)
+		h.Write([]byte(name + "\n" + text + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// List returns all known templates and where each one currently comes from.
+func (s *Store) List() []Template {
+	entries, _ := fs.Glob(defaults, "*.tmpl")
+	var out []Template
+	for _, e := range entries {
+		name := strings.TrimSuffix(e, ".tmpl")
+		src := SourceDefault
+		if s.overridePath(name) != "" {
+			src = SourceOverride
+		}
+		out = append(out, Template{Name: name, Source: src})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *Store) load(name string) (string, Source, error) {
+	if path := s.overridePath(name); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", err
+		}
+		return string(data), SourceOverride, nil
+	}
+	data, err := defaults.ReadFile(name + ".tmpl")
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", fmt.Errorf("unknown prompt %q", name)
+	}
+	return string(data), SourceDefault, err
+}
+
+// overridePath returns the override file for name, or "" if there is none.
+func (s *Store) overridePath(name string) string {
+	if s.dir == "" {
+		return ""
+	}
+	path := filepath.Join(s.dir, name+".tmpl")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
@@ -18,7 +18,7 @@ function saveDisplayName(input) {
     fetch('/api/settings', {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ displayName: input.value.trim() || 'Lian' })
+      body: JSON.stringify({ displayName: input.value.trim() })
     }).catch(console.error);
   }, 500);
 }
@@ -26,10 +26,71 @@ function saveDisplayName(input) {
 function updateAvatarInitials(name) {
   const el = document.getElementById('avatar-initials');
   if (!el) return;
-  const trimmed = (name || '').trim();
+  const input = document.getElementById('display-name');
+  const trimmed = (name || '').trim() || (input ? input.placeholder : '');
   el.textContent = trimmed ? trimmed.charAt(0).toUpperCase() : '?';
 }
 
+// Teachers
+function addTeacher() {
+  const input = document.getElementById('teacher-username');
+  const error = document.getElementById('teacher-error');
+  const username = input.value.trim();
+  if (!username) return;
+  fetch('/api/teachers', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ username: username })
+  })
+    .then(res => res.json())
+    .then(data => {
+      if (data.error) { error.textContent = data.error; return; }
+      location.reload();
+    })
+    .catch(console.error);
+}
+
+function removeTeacher(id) {
+  fetch('/api/teachers/' + encodeURIComponent(id), { method: 'DELETE' })
+    .then(() => location.reload())
+    .catch(console.error);
+}
+
+// API tokens
+function createAPIToken() {
+  const input = document.getElementById('api-token-name');
+  const hint = document.getElementById('api-token-hint');
+  const name = input.value.trim();
+  if (!name) return;
+  fetch('/api/tokens', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ name: name })
+  })
+    .then(res => res.json())
+    .then(data => {
+      if (data.error) { hint.textContent = data.error; return; }
+      input.value = '';
+      document.getElementById('api-token-value').value = data.token;
+      document.getElementById('api-token-created').style.display = '';
+      hint.textContent = 'Copy this token now. It won\'t be shown again.';
+    })
+    .catch(console.error);
+}
+
+function copyAPIToken() {
+  const input = document.getElementById('api-token-value');
+  input.select();
+  if (navigator.clipboard) navigator.clipboard.writeText(input.value).catch(console.error);
+}
+
+function revokeAPIToken(id) {
+  if (!confirm('Revoke this token? Apps using it will stop working.')) return;
+  fetch('/api/tokens/' + encodeURIComponent(id), { method: 'DELETE' })
+    .then(() => location.reload())
+    .catch(console.error);
+}
+
 // Theme
 function setTheme(theme) {
   const shell = document.getElementById('app-shell');
@@ -114,28 +175,222 @@ function closeSearch() {
   }
 }
 
+// ===== Song Detail: Revisions =====
+// The page remembers the song revision it rendered. Writes that depend on
+// that state send it as If-Match; the server answers 409 with the current
+// song when another tab or device changed it in between.
+function songRevision() {
+  var view = document.getElementById('exercise-view');
+  return view && view.dataset.revision ? parseInt(view.dataset.revision) : null;
+}
+
+function setSongRevision(rev) {
+  var view = document.getElementById('exercise-view');
+  if (view && rev) view.dataset.revision = rev;
+}
+
+// songWrite sends a JSON write for the song on this page and resolves to
+// { ok, status, data }. An unconditional write only advances the page's
+// revision when it is the next one, so a later conditional write still
+// notices changes made elsewhere in between.
+function songWrite(url, method, body, ifMatch) {
+  var headers = { 'Content-Type': 'application/json' };
+  var rev = songRevision();
+  if (ifMatch && rev !== null) headers['If-Match'] = '"' + rev + '"';
+  _songWriteStarted();
+  return fetch(url, { method: method, headers: headers, body: JSON.stringify(body) })
+    .then(function(res) {
+      return res.json().catch(function() { return {}; }).then(function(data) {
+        if (res.ok && data.revision && (ifMatch || data.revision === songRevision() + 1)) {
+          setSongRevision(data.revision);
+        }
+        return { ok: res.ok, status: res.status, data: data };
+      });
+    })
+    .finally(_songWriteDone);
+}
+
+// whenSongWritesSettle runs fn once no songWrite is in flight, so live
+// updates can tell this page's own writes from changes made elsewhere.
+var _songWritesPending = 0;
+var _songWritesSettled = [];
+function whenSongWritesSettle(fn) {
+  if (_songWritesPending === 0) fn();
+  else _songWritesSettled.push(fn);
+}
+
+function _songWriteStarted() {
+  _songWritesPending++;
+}
+
+function _songWriteDone() {
+  if (--_songWritesPending > 0) return;
+  var settled = _songWritesSettled;
+  _songWritesSettled = [];
+  settled.forEach(function(fn) { fn(); });
+}
+
+// ===== Offline Sync Queue =====
+// Practice time, reps and stage changes are queued in localStorage and sent
+// to /api/sync in batches, so they survive bad Wi-Fi and page reloads. Each
+// operation has its own ID; the server applies it once however often it is
+// resent, so every tab can flush the same queue. Each account has its own
+// queue, keyed by the avoidnt_user cookie, so changes made by one user are
+// never sent with another user's session.
+var SYNC_QUEUE_KEY = 'avoidnt-sync-queue';
+var SYNC_BATCH = 500;
+var SYNC_RETRY_MS = 30000;
+var _syncFlushing = false;
+var _syncRetry = null;
+
+// _syncQueueKey returns the signed-in user's queue key, or null when nobody
+// is signed in (public share pages).
+function _syncQueueKey() {
+  var m = document.cookie.match(/(?:^|;\s*)avoidnt_user=([^;]+)/);
+  return m ? SYNC_QUEUE_KEY + ':' + decodeURIComponent(m[1]) : null;
+}
+
+function _syncQueue() {
+  var key = _syncQueueKey();
+  if (!key) return [];
+  try {
+    return JSON.parse(localStorage.getItem(key)) || [];
+  } catch (e) {
+    return [];
+  }
+}
+
+function _saveSyncQueue(queue) {
+  var key = _syncQueueKey();
+  if (!key) return;
+  try {
+    localStorage.setItem(key, JSON.stringify(queue));
+  } catch (e) {
+    console.error('Could not save sync queue', e);
+  }
+}
+
+function _newOpId() {
+  var bytes = new Uint8Array(16);
+  crypto.getRandomValues(bytes);
+  return Array.from(bytes, function(b) { return b.toString(16).padStart(2, '0'); }).join('');
+}
+
+// queueSyncOp records an operation ({ type, songId, exerciseId, ... }) and
+// tries to send it straight away.
+function queueSyncOp(op) {
+  op.id = _newOpId();
+  op.at = new Date().toISOString();
+  var queue = _syncQueue();
+  queue.push(op);
+  _saveSyncQueue(queue);
+  flushSyncQueue();
+}
+
+function flushSyncQueue() {
+  if (_syncFlushing || !navigator.onLine) return;
+  var batch = _syncQueue().slice(0, SYNC_BATCH);
+  if (!batch.length) return;
+
+  _syncFlushing = true;
+  clearTimeout(_syncRetry);
+  _syncRetry = null;
+  _songWriteStarted();
+  var more = false;
+  fetch('/api/sync', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ operations: batch })
+  })
+    .then(function(res) {
+      if (!res.ok) throw new Error('Sync failed (' + res.status + ')');
+      return res.json();
+    })
+    .then(function(data) {
+      var done = {};
+      var failed = false;
+      var latest = {}; // last result per exercise on this page
+      var songId = _syncPageSongId();
+      data.results.forEach(function(r, i) {
+        var op = batch[i];
+        if (r.status === 'error') { failed = true; return; }
+        done[r.id] = true;
+        if (r.status === 'rejected') console.warn('Sync operation rejected:', r.error, op);
+        if (op.songId !== songId) return;
+        if (r.revision && r.revision === songRevision() + 1) setSongRevision(r.revision);
+        if (r.exercise) latest[r.exercise.id] = r;
+      });
+      _saveSyncQueue(_syncQueue().filter(function(op) { return !done[op.id]; }));
+      Object.keys(latest).forEach(function(id) { _applySyncResult(latest[id]); });
+      if (failed) throw new Error('Some operations could not be saved');
+      more = batch.length === SYNC_BATCH;
+    })
+    .catch(function(err) {
+      console.error(err);
+      _syncRetry = setTimeout(flushSyncQueue, SYNC_RETRY_MS);
+    })
+    .finally(function() {
+      _syncFlushing = false;
+      _songWriteDone();
+      // Ops queued while this batch was in flight
+      if (more || (_syncQueue().length && !_syncRetry)) flushSyncQueue();
+    });
+}
+
+function _syncPageSongId() {
+  var view = document.getElementById('exercise-view');
+  return view ? view.dataset.songId : null;
+}
+
+// _applySyncResult shows the server's copy of a synced exercise, unless more
+// changes to it are still queued.
+function _applySyncResult(r) {
+  var pending = _syncQueue().some(function(op) { return op.exerciseId === r.exercise.id; });
+  if (pending) return;
+  if (r.status === 'conflict') {
+    var card = document.getElementById('card-' + r.exercise.id);
+    if (card) applyCardStage(card, r.exercise.stage);
+    return;
+  }
+  if (typeof _liveApplyExercise === 'function') _liveApplyExercise(r.exercise);
+}
+
+window.addEventListener('online', flushSyncQueue);
+document.addEventListener('DOMContentLoaded', function() {
+  // The queue used to be shared by every account; its owner is unknown
+  try { localStorage.removeItem(SYNC_QUEUE_KEY); } catch (e) {}
+  flushSyncQueue();
+});
+
+// The service worker keeps visited pages and assets available offline
+if ('serviceWorker' in navigator) {
+  navigator.serviceWorker.register('/sw.js').catch(console.error);
+}
+
 // ===== Song Detail: Stage Change =====
 function onStageChange(select, songId, exerciseId) {
   const newStage = parseInt(select.value);
-  fetch('/api/songs/' + songId + '/exercises/' + exerciseId, {
-    method: 'PATCH',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ stage: newStage })
-  }).catch(console.error);
+  queueSyncOp({ type: 'stage', songId: songId, exerciseId: exerciseId, stage: newStage });
 
-  // Update card colors
   const card = select.closest('.expanded-card-wrapper');
-  if (card) {
-    const colors = { 1:'#ef4444', 2:'#f97316', 3:'#eab308', 4:'#84cc16', 5:'#22c55e' };
-    const color = colors[newStage] || '#9ca3af';
-    select.style.color = color;
-    var innerCard = card.querySelector('.expanded-card');
-    if (innerCard) innerCard.style.borderLeftColor = hexToRGBA(color, 0.7);
-    card.dataset.stage = newStage;
+  if (card) applyCardStage(card, newStage);
+}
 
-    // Update section nav pill for this exercise's section
-    updateSectionPill(card.dataset.sectionId);
+// Update a card's stage select, colors and section pill
+function applyCardStage(card, stage) {
+  const colors = { 1:'#ef4444', 2:'#f97316', 3:'#eab308', 4:'#84cc16', 5:'#22c55e' };
+  const color = colors[stage] || '#9ca3af';
+  const select = card.querySelector('.card-stage-select');
+  if (select) {
+    select.value = stage;
+    select.style.color = color;
   }
+  var innerCard = card.querySelector('.expanded-card');
+  if (innerCard) innerCard.style.borderLeftColor = hexToRGBA(color, 0.7);
+  card.dataset.stage = stage;
+
+  // Update section nav pill for this exercise's section
+  updateSectionPill(card.dataset.sectionId);
 }
 
 function updateSectionPill(sectionId) {
@@ -348,7 +603,7 @@ function toggleEditExercises() {
   toolbar.style.display = editing ? 'block' : 'none';
 }
 
-// Regenerate HD previews from source pages
+// Regenerate HD previews from source pages (runs as a background job)
 function regeneratePreviews(btn) {
   var toolbar = document.getElementById('edit-exercises-toolbar');
   if (!toolbar) return;
@@ -357,28 +612,52 @@ function regeneratePreviews(btn) {
   btn.disabled = true;
   btn.textContent = '⏳ Regenerating...';
 
-  fetch('/api/songs/' + songId + '/regenerate-previews', { method: 'POST' })
-    .then(function(res) { return res.json(); })
-    .then(function(data) {
-      if (data.error) {
-        alert('Error: ' + data.error);
-        btn.textContent = '🔄 Regenerate HD Previews';
-      } else {
-        btn.textContent = '✅ Done! (' + (data.regenerated || 0) + ' crops)';
-        // Reload images by busting cache
+  function fail(msg) {
+    alert(msg);
+    btn.textContent = '🔄 Regenerate HD Previews';
+    btn.disabled = false;
+  }
+
+  function poll(jobId) {
+    fetch('/api/preview-jobs/' + jobId)
+      .then(function(res) { return res.json(); })
+      .then(function(job) {
+        if (job.error) { fail('Error: ' + job.error); return; }
+        if (job.status !== 'done') {
+          btn.textContent = '⏳ Regenerating... (' + job.completed + '/' + job.total + ')';
+          setTimeout(function() { poll(jobId); }, 1000);
+          return;
+        }
+        var label = '✅ Done! (' + job.regenerated + ' crops';
+        if (job.failures && job.failures.length) label += ', ' + job.failures.length + ' failed';
+        btn.textContent = label + ')';
+        btn.disabled = false;
+        // Point images at their new content-versioned URLs
+        var previews = job.previews || {};
         document.querySelectorAll('.card-crop-img').forEach(function(img) {
           var src = img.getAttribute('src');
-          if (src) img.src = src.split('?')[0] + '?t=' + Date.now();
+          var hash = previews[img.dataset.cropId];
+          if (src && hash) {
+            img.removeAttribute('srcset');
+            img.src = src.split('?')[0] + '?v=' + hash;
+          }
         });
-      }
+      })
+      .catch(function(err) {
+        console.error(err);
+        fail('Failed to check regeneration progress');
+      });
+  }
+
+  fetch('/api/songs/' + songId + '/regenerate-previews', { method: 'POST' })
+    .then(function(res) { return res.json(); })
+    .then(function(data) {
+      if (data.error) { fail('Error: ' + data.error); return; }
+      poll(data.jobId);
     })
     .catch(function(err) {
       console.error(err);
-      alert('Failed to regenerate previews');
-      btn.textContent = '🔄 Regenerate HD Previews';
-    })
-    .finally(function() {
-      btn.disabled = false;
+      fail('Failed to regenerate previews');
     });
 }
 
@@ -393,11 +672,8 @@ function cropZoomBtn(btn, delta) {
   var songId = card.dataset.songId;
   var exerciseId = card.dataset.exerciseId;
   if (songId && exerciseId) {
-    fetch('/api/songs/' + songId + '/exercises/' + exerciseId, {
-      method: 'PATCH',
-      headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ cropScale: newScale })
-    }).catch(console.error);
+    songWrite('/api/songs/' + songId + '/exercises/' + exerciseId, 'PATCH', { cropScale: newScale })
+      .catch(console.error);
   }
 }
 
@@ -470,11 +746,8 @@ function setCropBgColor(swatch, color) {
   });
 
   // Save to server
-  fetch('/api/songs/' + songId + '/display', {
-    method: 'PATCH',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ cropBgColor: color })
-  }).catch(console.error);
+  songWrite('/api/songs/' + songId + '/display', 'PATCH', { cropBgColor: color })
+    .catch(console.error);
 }
 
 // Apply initial crop bg styles on page load (for dark backgrounds)
@@ -848,11 +1121,8 @@ function toggleCleanView(key) {
   if (!field) return;
   var body = {};
   body[field] = isNowHidden;
-  fetch('/api/songs/' + songId + '/display', {
-    method: 'PATCH',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify(body)
-  }).catch(console.error);
+  songWrite('/api/songs/' + songId + '/display', 'PATCH', body)
+    .catch(console.error);
 }
 
 // ===== Master Zoom =====
@@ -877,11 +1147,8 @@ function masterZoom(delta) {
     var songId = card.dataset.songId;
     var exerciseId = card.dataset.exerciseId;
     if (songId && exerciseId) {
-      fetch('/api/songs/' + songId + '/exercises/' + exerciseId, {
-        method: 'PATCH',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ cropScale: newScale })
-      }).catch(console.error);
+      songWrite('/api/songs/' + songId + '/exercises/' + exerciseId, 'PATCH', { cropScale: newScale })
+        .catch(console.error);
     }
   });
 }
@@ -0,0 +1,98 @@
+// ===== Live Updates =====
+// Keeps the song page in step with changes made on other devices or tabs
+// through the /api/events stream. Events this page caused itself are
+// recognised by their revision and skipped.
+var _liveSource = null;
+
+function startLiveUpdates() {
+  var view = document.getElementById('exercise-view');
+  if (!view || !window.EventSource || _liveSource) return;
+  var songId = view.dataset.songId;
+  _liveSource = new EventSource('/api/events?songId=' + encodeURIComponent(songId));
+
+  _liveSource.addEventListener('exercise', function(e) {
+    var ev = JSON.parse(e.data);
+    whenSongWritesSettle(function() {
+      var rev = songRevision();
+      if (rev !== null && ev.revision <= rev) return;
+      _liveApplyExercise(ev.data);
+      if (rev !== null && ev.revision === rev + 1) setSongRevision(ev.revision);
+    });
+  });
+
+  _liveSource.addEventListener('song', function(e) {
+    var ev = JSON.parse(e.data);
+    whenSongWritesSettle(function() {
+      var rev = songRevision();
+      if (rev !== null && ev.revision <= rev) return;
+      _liveShowBanner('This song was changed on another device.', true);
+    });
+  });
+
+  _liveSource.addEventListener('song-deleted', function() {
+    _liveShowBanner('This song was deleted on another device.', false);
+  });
+
+  _liveSource.addEventListener('daily-log', _liveRefreshStats);
+  _liveSource.addEventListener('stage-log', _liveRefreshStats);
+}
+
+function _liveApplyExercise(ex) {
+  if (!ex) return;
+  var card = document.getElementById('card-' + ex.id);
+  if (card) {
+    if (String(ex.stage) !== card.dataset.stage) applyCardStage(card, ex.stage);
+
+    // The card being timed keeps its local count; its next save rebases
+    // onto the server's total.
+    if (typeof _activeCard === 'undefined' || _activeCard !== card) {
+      card.dataset.totalSeconds = ex.totalPracticedSeconds;
+      _updateCardTimerDisplay(card, ex.totalPracticedSeconds);
+    }
+    card.dataset.totalReps = ex.totalReps;
+    var reps = card.querySelector('.card-reps-count');
+    if (reps) reps.textContent = ex.totalReps;
+
+    var scale = ex.cropScale || 100;
+    if (String(scale) !== card.dataset.cropScale) {
+      card.dataset.cropScale = scale;
+      applyCropScaleToCard(card, scale);
+    }
+  }
+
+  // Keep the stats drawer's copy of the song current
+  if (typeof _statsSongData !== 'undefined' && _statsSongData && _statsSongData.exercises) {
+    _statsSongData.exercises.forEach(function(old, i) {
+      if (old.id === ex.id) _statsSongData.exercises[i] = ex;
+    });
+  }
+  _liveRefreshStats();
+}
+
+function _liveRefreshStats() {
+  if (typeof _statsDrawerOpen !== 'undefined' && _statsDrawerOpen) _renderActiveTab();
+}
+
+function _liveShowBanner(message, canReload) {
+  var banner = document.getElementById('live-banner');
+  if (!banner) {
+    banner = document.createElement('div');
+    banner.id = 'live-banner';
+    banner.className = 'live-banner';
+    document.body.appendChild(banner);
+  }
+  banner.textContent = message + ' ';
+  var btn = document.createElement('button');
+  btn.className = 'live-banner-btn';
+  if (canReload) {
+    btn.textContent = 'Reload';
+    btn.onclick = function() { location.reload(); };
+  } else {
+    btn.textContent = 'Back to songs';
+    btn.onclick = function() { location.href = '/songs'; };
+  }
+  banner.appendChild(btn);
+}
+
+// app.js is deferred, so wait for it before listening
+document.addEventListener('DOMContentLoaded', startLiveUpdates);
@@ -12,6 +12,7 @@
   // State
   let mode, songId, createdAt;
   let songTitle = '', artist = '', tempo = null, youtubeUrl = null, spotifyUrl = null;
+  let keyLabel = null, timeSignature = null, capo = null, tuning = null; // keyLabel is key + mode, e.g. "E minor"
   let structure = [];
   let jobId = null, pageCount = 0;
   let exercises = [];
@@ -127,6 +128,10 @@
     songTitle = song.title || '';
     artist = song.artist || '';
     tempo = song.tempo;
+    keyLabel = song.key ? song.key + (song.mode ? ' ' + song.mode : '') : null;
+    timeSignature = song.timeSignature || null;
+    capo = song.capo || null;
+    tuning = song.tuning || null;
     youtubeUrl = song.youtubeUrl;
     spotifyUrl = song.spotifyUrl;
     structure = (song.structure || []).map(s => ({ ...s }));
@@ -149,6 +154,7 @@
           id: ex.id,
           crops: ex.crops.map(crop => ({
             cropId: crop.id,
+            previewHash: crop.previewHash || '',
             pageIndex: crop.pageIndex,
             rect: { ...crop.rect },
             previewDataUrl: null,
@@ -230,6 +236,21 @@
       }
     }
 
+    // Key, time signature, capo, tuning
+    [
+      ['pd-key-display', keyLabel, 'Key'],
+      ['pd-timesig-display', timeSignature, 'Time'],
+      ['pd-capo-display', capo ? 'Capo ' + capo : null, 'Capo'],
+      ['pd-tuning-display', tuning, 'Tuning']
+    ].forEach(function(f) {
+      var chip = document.getElementById(f[0]);
+      if (!chip || chip.querySelector('.pd-inline-input')) return;
+      var text = chip.querySelector('.pd-display-text');
+      if (!text) return;
+      text.textContent = f[1] || f[2];
+      text.classList.toggle('pd-placeholder', !f[1]);
+    });
+
     // YouTube
     var ytEl = document.getElementById('pd-youtube-display');
     if (ytEl && !ytEl.querySelector('.pd-inline-input')) {
@@ -287,6 +308,10 @@
       title: { elId: 'pd-title-display', type: 'text', placeholder: 'Song title', getValue: function() { return songTitle; }, setValue: function(v) { songTitle = v; } },
       artist: { elId: 'pd-artist-display', type: 'text', placeholder: 'Artist', getValue: function() { return artist; }, setValue: function(v) { artist = v; } },
       tempo: { elId: 'pd-tempo-display', type: 'text', placeholder: 'BPM', inputmode: 'numeric', getValue: function() { return tempo || ''; }, setValue: function(v) { tempo = v ? parseFloat(v) : null; } },
+      key: { elId: 'pd-key-display', type: 'text', placeholder: 'e.g. E minor', getValue: function() { return keyLabel || ''; }, setValue: function(v) { keyLabel = v || null; } },
+      timesig: { elId: 'pd-timesig-display', type: 'text', placeholder: '4/4', inputmode: 'text', getValue: function() { return timeSignature || ''; }, setValue: function(v) { timeSignature = v || null; } },
+      capo: { elId: 'pd-capo-display', type: 'text', placeholder: 'Fret', inputmode: 'numeric', getValue: function() { return capo || ''; }, setValue: function(v) { capo = parseInt(v, 10) || null; } },
+      tuning: { elId: 'pd-tuning-display', type: 'text', placeholder: 'e.g. Drop D', getValue: function() { return tuning || ''; }, setValue: function(v) { tuning = v || null; } },
       youtube: { elId: 'pd-youtube-display', type: 'url', placeholder: 'YouTube URL', getValue: function() { return youtubeUrl || ''; }, setValue: function(v) { youtubeUrl = v || null; } },
       spotify: { elId: 'pd-spotify-display', type: 'url', placeholder: 'Spotify URL', getValue: function() { return spotifyUrl || ''; }, setValue: function(v) { spotifyUrl = v || null; } }
     };
@@ -507,7 +532,9 @@
   }
 
   function loadCropPreview(sId, card, crop) {
-    fetch('/api/songs/' + sId + '/preview/' + crop.cropId)
+    var url = '/api/songs/' + sId + '/preview/' + crop.cropId;
+    if (crop.previewHash) url += '?v=' + crop.previewHash;
+    fetch(url)
       .then(res => {
         if (!res.ok) throw new Error();
         return res.blob();
@@ -1253,7 +1280,7 @@
       var metadataRes = await fetch('/api/analyze-pdf', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ pageImages: pageImages })
+        body: JSON.stringify({ pageImages: pageImages, jobId: jobId || '' })
       });
 
       if (!metadataRes.ok) {
@@ -1267,6 +1294,10 @@
       if (metadata.title && !songTitle.trim()) { songTitle = metadata.title; }
       if (metadata.artist && !artist.trim()) { artist = metadata.artist; }
       if (metadata.tempo !== null && metadata.tempo !== undefined && tempo === null) { tempo = metadata.tempo; }
+      if (metadata.key && !keyLabel) { keyLabel = metadata.key + (metadata.mode ? ' ' + metadata.mode : ''); }
+      if (metadata.timeSignature && !timeSignature) { timeSignature = metadata.timeSignature; }
+      if (metadata.capo && !capo) { capo = metadata.capo; }
+      if (metadata.tuning && !tuning) { tuning = metadata.tuning; }
       renderHeader();
 
       if (metadata.sections && metadata.sections.length > 0 && structure.length === 0) {
@@ -1306,6 +1337,7 @@
             songTitle: songTitle || '',
             artist: artist || '',
             pageImages: pageImages,
+            jobId: jobId || '',
             sections: structure.map(function(s) { return { id: s.id, type: s.type, order: s.order }; }),
             exercises: exerciseInputs
           })
@@ -1367,10 +1399,128 @@
     }
   };
 
+  // ===== AI exercise detection (draft crops from page images) =====
+  window.pdProposeExercises = async function() {
+    if (!jobId || pageCount === 0) return;
+    var btn = document.getElementById('pd-propose-btn');
+    var errEl = document.getElementById('pd-analyze-error');
+    var infoEl = document.getElementById('pd-autofill-info');
+    if (btn) { btn.disabled = true; btn.innerHTML = '<span class="spinner-small"></span> Detecting\u2026'; }
+    if (errEl) errEl.style.display = 'none';
+    if (infoEl) infoEl.style.display = 'none';
+
+    try {
+      var res = await fetch('/api/propose-exercises', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          songTitle: songTitle || '',
+          artist: artist || '',
+          jobId: jobId,
+          pageCount: pageCount,
+          sections: structure.map(function(s) { return { id: s.id, type: s.type, order: s.order }; })
+        })
+      });
+      if (!res.ok) {
+        var body = await res.json().catch(function() { return null; });
+        throw new Error((body && body.error) || 'Detection failed (' + res.status + ')');
+      }
+      var proposal = await res.json();
+
+      // Adopt any sections the AI added; existing ones come back unchanged
+      var known = {};
+      structure.forEach(function(s) { known[s.id] = true; });
+      (proposal.sections || []).forEach(function(s) {
+        if (!known[s.id]) structure.push({ id: s.id, type: s.type, order: structure.length });
+      });
+
+      var imgs = pagesInner ? pagesInner.querySelectorAll('.page-image') : [];
+      var added = 0;
+      (proposal.exercises || []).forEach(function(p) {
+        var card = buildProposedCard(p, imgs);
+        if (!card) return;
+        exercises.push(card);
+        added++;
+      });
+
+      if (infoEl) {
+        infoEl.textContent = added > 0
+          ? 'Added ' + added + ' draft exercise' + (added === 1 ? '' : 's') + '. Review and adjust before saving.'
+          : 'No exercises detected.';
+        infoEl.style.display = 'block';
+      }
+
+      isDirty = true;
+      renderSectionPills();
+      renderExercises();
+      updateExerciseUI();
+      refreshAllCropOverlays();
+      updateSaveState();
+      updateHint();
+    } catch(err) {
+      if (errEl) { errEl.textContent = err.message; errEl.style.display = 'block'; }
+    } finally {
+      if (btn) { btn.innerHTML = '\u2728 Detect exercises'; }
+      updateAutoFillBtn();
+    }
+  };
+
+  // buildProposedCard crops the loaded page images to the proposed rects so
+  // the draft looks exactly like a hand-drawn exercise.
+  function buildProposedCard(p, imgs) {
+    var crops = [];
+    var canvases = [];
+    (p.crops || []).forEach(function(c) {
+      var img = imgs[c.pageIndex];
+      if (!img || !img.complete || img.naturalWidth === 0) return;
+      var sx = Math.floor(c.rect.x * img.naturalWidth);
+      var sy = Math.floor(c.rect.y * img.naturalHeight);
+      var sw = Math.floor(c.rect.w * img.naturalWidth);
+      var sh = Math.floor(c.rect.h * img.naturalHeight);
+      if (sw <= 0 || sh <= 0) return;
+      var cvs = document.createElement('canvas');
+      cvs.width = sw; cvs.height = sh;
+      cvs.getContext('2d').drawImage(img, sx, sy, sw, sh, 0, 0, sw, sh);
+      var url = cvs.toDataURL('image/png');
+      canvases.push(cvs);
+      crops.push({
+        cropId: c.id,
+        pageIndex: c.pageIndex,
+        rect: c.rect,
+        previewDataUrl: url,
+        previewBase64: url.split(',')[1]
+      });
+    });
+    if (crops.length === 0) return null;
+
+    var combined = document.createElement('canvas');
+    combined.width = Math.max.apply(null, canvases.map(function(c) { return c.width; }));
+    combined.height = canvases.reduce(function(h, c) { return h + c.height; }, 0);
+    var ctx = combined.getContext('2d');
+    var yOff = 0;
+    canvases.forEach(function(c) { ctx.drawImage(c, 0, yOff); yOff += c.height; });
+
+    var card = {
+      id: p.id,
+      crops: crops,
+      previewDataUrl: combined.toDataURL('image/png'),
+      sequenceNumber: exercises.length + 1,
+      description: p.name || '',
+      sectionId: p.sectionId || '',
+      difficulty: 1,
+      cropScale: 100,
+      isComplete: false
+    };
+    updateCompleteness(card);
+    return card;
+  }
+
   function updateAutoFillBtn() {
     var btn = document.getElementById('pd-autofill-btn');
     var section = document.getElementById('pd-autofill-section');
     if (btn) btn.disabled = !(jobId && pageCount > 0);
+    var proposeBtn = document.getElementById('pd-propose-btn');
+    if (proposeBtn) proposeBtn.disabled = !(jobId && pageCount > 0);
     if (section) section.style.display = (jobId && pageCount > 0) ? '' : 'none';
   }
 
@@ -1432,6 +1582,11 @@
       title: songTitle.trim(),
       artist: artist.trim(),
       tempo: tempo,
+      key: keyLabel, // the server splits off the mode
+      mode: null,
+      timeSignature: timeSignature,
+      capo: capo,
+      tuning: tuning,
       youtubeUrl: youtubeUrl,
       spotifyUrl: spotifyUrl,
       jobId: jobId,
@@ -0,0 +1,191 @@
+// ===== Share & Assign =====
+var _shareStudentsLoaded = false;
+
+function _shareModal() {
+  return document.getElementById('share-modal');
+}
+
+function openShareModal() {
+  var backdrop = document.getElementById('share-modal-backdrop');
+  if (!backdrop) return;
+  backdrop.style.display = '';
+  _updateShareLink();
+  _loadPublicLinks();
+  if (!_shareStudentsLoaded) _loadStudents();
+}
+
+function closeShareModal() {
+  var backdrop = document.getElementById('share-modal-backdrop');
+  if (backdrop) backdrop.style.display = 'none';
+}
+
+function _updateShareLink() {
+  var modal = _shareModal();
+  var input = document.getElementById('share-link-input');
+  if (modal && input) input.value = location.origin + modal.dataset.shareUrl;
+}
+
+function setSongShared(enabled) {
+  var modal = _shareModal();
+  if (!modal) return;
+  songWrite('/api/songs/' + modal.dataset.songId + '/share', 'PUT', { enabled: enabled })
+    .then(function(res) {
+      var data = res.data;
+      if (data.error) throw new Error(data.error);
+      modal.dataset.shareUrl = data.url;
+      _updateShareLink();
+      document.getElementById('share-link-row').style.display = data.sharedReadOnly ? '' : 'none';
+    })
+    .catch(function(err) {
+      console.error(err);
+      document.getElementById('share-readonly-toggle').checked = !enabled;
+    });
+}
+
+function copyShareLink() {
+  var input = document.getElementById('share-link-input');
+  if (!input) return;
+  input.select();
+  if (navigator.clipboard) {
+    navigator.clipboard.writeText(input.value).catch(console.error);
+  }
+}
+
+function _loadPublicLinks() {
+  var modal = _shareModal();
+  var list = document.getElementById('share-public-list');
+  if (!modal || !list) return;
+  fetch('/api/songs/' + modal.dataset.songId + '/share-links')
+    .then(function(res) { return res.json(); })
+    .then(function(links) {
+      if (links.error) throw new Error(links.error);
+      list.innerHTML = '';
+      links.forEach(function(l) { list.appendChild(_publicLinkRow(l)); });
+    })
+    .catch(function(err) {
+      console.error(err);
+      list.innerHTML = '<span class="share-modal-desc">Failed to load links</span>';
+    });
+}
+
+function _publicLinkRow(link) {
+  var row = document.createElement('div');
+  row.className = 'share-public-link' + (link.expired ? ' expired' : '');
+  var label = document.createElement('span');
+  var expiry = link.expired ? 'expired'
+    : link.expiresAt ? 'until ' + new Date(link.expiresAt).toLocaleDateString() : 'no expiry';
+  label.textContent = link.accessCount + ' view' + (link.accessCount === 1 ? '' : 's') + ' · ' + expiry;
+  row.appendChild(label);
+
+  var actions = document.createElement('span');
+  actions.className = 'share-public-link-actions';
+  if (!link.expired) {
+    var copy = document.createElement('button');
+    copy.className = 'settings-btn-secondary';
+    copy.textContent = 'Copy';
+    copy.onclick = function() {
+      if (navigator.clipboard) navigator.clipboard.writeText(location.origin + link.url).catch(console.error);
+    };
+    actions.appendChild(copy);
+  }
+  var revoke = document.createElement('button');
+  revoke.className = 'settings-btn-secondary';
+  revoke.textContent = 'Revoke';
+  revoke.onclick = function() { revokePublicLink(link.id); };
+  actions.appendChild(revoke);
+  row.appendChild(actions);
+  return row;
+}
+
+function createPublicLink() {
+  var modal = _shareModal();
+  if (!modal) return;
+  var days = parseInt(document.getElementById('share-public-expiry').value, 10) || 0;
+  fetch('/api/songs/' + modal.dataset.songId + '/share-links', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ expiresInDays: days })
+  })
+    .then(function(res) { return res.json(); })
+    .then(function(data) {
+      if (data.error) throw new Error(data.error);
+      if (navigator.clipboard) navigator.clipboard.writeText(location.origin + data.link.url).catch(console.error);
+      _loadPublicLinks();
+    })
+    .catch(console.error);
+}
+
+function revokePublicLink(id) {
+  var modal = _shareModal();
+  if (!modal) return;
+  fetch('/api/songs/' + modal.dataset.songId + '/share-links/' + encodeURIComponent(id), { method: 'DELETE' })
+    .then(function() { _loadPublicLinks(); })
+    .catch(console.error);
+}
+
+function _loadStudents() {
+  var list = document.getElementById('share-student-list');
+  fetch('/api/students')
+    .then(function(res) { return res.json(); })
+    .then(function(students) {
+      _shareStudentsLoaded = true;
+      list.innerHTML = '';
+      if (!students.length) {
+        list.innerHTML = '<span class="share-modal-desc">No students yet. Students add you as their teacher in Settings.</span>';
+        return;
+      }
+      students.forEach(function(s) {
+        var label = document.createElement('label');
+        label.className = 'share-student';
+        var box = document.createElement('input');
+        box.type = 'checkbox';
+        box.value = s.id;
+        box.onchange = _updateAssignButton;
+        label.appendChild(box);
+        label.appendChild(document.createTextNode(' ' + s.username));
+        list.appendChild(label);
+      });
+    })
+    .catch(function(err) {
+      console.error(err);
+      list.innerHTML = '<span class="share-modal-desc">Failed to load students</span>';
+    });
+}
+
+function _selectedStudents() {
+  var boxes = document.querySelectorAll('#share-student-list input[type=checkbox]:checked');
+  return Array.prototype.map.call(boxes, function(b) { return b.value; });
+}
+
+function _updateAssignButton() {
+  document.getElementById('share-assign-btn').disabled = _selectedStudents().length === 0;
+}
+
+function assignToStudents() {
+  var modal = _shareModal();
+  var ids = _selectedStudents();
+  if (!modal || !ids.length) return;
+  var status = document.getElementById('share-assign-status');
+  var btn = document.getElementById('share-assign-btn');
+  btn.disabled = true;
+  status.textContent = 'Assigning…';
+
+  fetch('/api/songs/' + modal.dataset.songId + '/assign', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ studentIds: ids })
+  })
+    .then(function(res) { return res.json(); })
+    .then(function(data) {
+      if (data.error) throw new Error(data.error);
+      var labels = { assigned: 'assigned', already_assigned: 'already has it', not_a_student: 'not your student', failed: 'failed' };
+      status.textContent = data.results.map(function(r) {
+        return (r.username || r.studentId) + ': ' + (labels[r.status] || r.status);
+      }).join(' · ');
+      document.querySelectorAll('#share-student-list input[type=checkbox]').forEach(function(b) { b.checked = false; });
+    })
+    .catch(function(err) {
+      status.textContent = 'Assign failed: ' + err.message;
+      btn.disabled = false;
+    });
+}
@@ -11,6 +11,7 @@
   let editing = false;
   let songId = '';
   let songTitle = '', artist = '', tempo = null, youtubeUrl = null, spotifyUrl = null;
+  let keyLabel = null, timeSignature = null, capo = null, tuning = null; // keyLabel is key + mode, e.g. "E minor"
   let structure = [];
   let exercises = [];
   let existingExercises = []; // original exercises w/ practice data
@@ -70,6 +71,10 @@
     songTitle = song.title || '';
     artist = song.artist || '';
     tempo = song.tempo;
+    keyLabel = song.key ? song.key + (song.mode ? ' ' + song.mode : '') : null;
+    timeSignature = song.timeSignature || null;
+    capo = song.capo || null;
+    tuning = song.tuning || null;
     youtubeUrl = song.youtubeUrl || null;
     spotifyUrl = song.spotifyUrl || null;
     structure = (song.structure || []).map(function(s) { return { id: s.id, type: s.type, order: s.order }; });
@@ -252,6 +257,10 @@
       title:   { elId: 'se-title-display',   type: 'text', placeholder: 'Song title', getValue: function() { return songTitle; }, setValue: function(v) { songTitle = v; } },
       artist:  { elId: 'se-artist-display',   type: 'text', placeholder: 'Artist', getValue: function() { return artist; }, setValue: function(v) { artist = v; } },
       tempo:   { elId: 'se-tempo-display',    type: 'text', placeholder: 'BPM', inputmode: 'numeric', getValue: function() { return tempo || ''; }, setValue: function(v) { tempo = v ? parseFloat(v) : null; } },
+      key:     { elId: 'se-key-display',      type: 'text', placeholder: 'e.g. E minor', getValue: function() { return keyLabel || ''; }, setValue: function(v) { keyLabel = v || null; } },
+      timesig: { elId: 'se-timesig-display',  type: 'text', placeholder: '4/4', inputmode: 'text', getValue: function() { return timeSignature || ''; }, setValue: function(v) { timeSignature = v || null; } },
+      capo:    { elId: 'se-capo-display',     type: 'text', placeholder: 'Fret', inputmode: 'numeric', getValue: function() { return capo || ''; }, setValue: function(v) { capo = parseInt(v, 10) || null; } },
+      tuning:  { elId: 'se-tuning-display',   type: 'text', placeholder: 'e.g. Drop D', getValue: function() { return tuning || ''; }, setValue: function(v) { tuning = v || null; } },
       youtube: { elId: 'se-youtube-display',  type: 'url', placeholder: 'YouTube URL', getValue: function() { return youtubeUrl || ''; }, setValue: function(v) { youtubeUrl = v || null; } },
       spotify: { elId: 'se-spotify-display',  type: 'url', placeholder: 'Spotify URL', getValue: function() { return spotifyUrl || ''; }, setValue: function(v) { spotifyUrl = v || null; } }
     };
@@ -322,6 +331,20 @@
         el.classList.toggle('pd-placeholder', !tempo);
       }
     }
+    var musicFields = {
+      key: ['#se-key-display', keyLabel, 'Key'],
+      timesig: ['#se-timesig-display', timeSignature, 'Time'],
+      capo: ['#se-capo-display', capo ? 'Capo ' + capo : null, 'Capo'],
+      tuning: ['#se-tuning-display', tuning, 'Tuning']
+    };
+    if (musicFields[field]) {
+      var f = musicFields[field];
+      var el = document.querySelector(f[0] + ' .pd-display-text');
+      if (el) {
+        el.textContent = f[1] || f[2];
+        el.classList.toggle('pd-placeholder', !f[1]);
+      }
+    }
     if (field === 'youtube') {
       var el = document.querySelector('#se-youtube-display .pd-display-text');
       if (el) el.classList.toggle('pd-placeholder', !youtubeUrl);
@@ -1155,7 +1178,7 @@
       var metadataRes = await fetch('/api/analyze-pdf', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ pageImages: pageImages })
+        body: JSON.stringify({ pageImages: pageImages, jobId: jobId || '' })
       });
 
       if (!metadataRes.ok) {
@@ -1169,6 +1192,10 @@
       if (metadata.title && !songTitle.trim()) { songTitle = metadata.title; updateHeaderDisplay('title'); }
       if (metadata.artist && !artist.trim()) { artist = metadata.artist; updateHeaderDisplay('artist'); }
       if (metadata.tempo && tempo === null) { tempo = metadata.tempo; updateHeaderDisplay('tempo'); }
+      if (metadata.key && !keyLabel) { keyLabel = metadata.key + (metadata.mode ? ' ' + metadata.mode : ''); updateHeaderDisplay('key'); }
+      if (metadata.timeSignature && !timeSignature) { timeSignature = metadata.timeSignature; updateHeaderDisplay('timesig'); }
+      if (metadata.capo && !capo) { capo = metadata.capo; updateHeaderDisplay('capo'); }
+      if (metadata.tuning && !tuning) { tuning = metadata.tuning; updateHeaderDisplay('tuning'); }
 
       if (metadata.sections && metadata.sections.length > 0 && structure.length === 0) {
         structure = metadata.sections.map(function(name, i) {
@@ -1201,6 +1228,7 @@
             songTitle: songTitle || '',
             artist: artist || '',
             pageImages: pageImages,
+            jobId: jobId || '',
             sections: structure.map(function(s) { return { id: s.id, type: s.type, order: s.order }; }),
             exercises: exerciseInputs
           })
@@ -1287,6 +1315,11 @@
       title: songTitle.trim(),
       artist: artist.trim(),
       tempo: tempo,
+      key: keyLabel, // the server splits off the mode
+      mode: null,
+      timeSignature: timeSignature,
+      capo: capo,
+      tuning: tuning,
       youtubeUrl: youtubeUrl,
       spotifyUrl: spotifyUrl,
       jobId: jobId,
@@ -1297,14 +1330,11 @@
     };
 
     try {
-      var res = await fetch('/api/songs', {
-        method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify(song)
-      });
+      // Conditional on the revision this page loaded, so edits made in
+      // another tab aren't overwritten
+      var res = await songWrite('/api/songs', 'POST', song, true);
       if (!res.ok) {
-        var body = await res.json().catch(function() { return null; });
-        throw new Error((body && body.error) || 'Save failed');
+        throw new Error(res.data.error || 'Save failed');
       }
       isDirty = false;
       // Reload the page to reflect saved state
@@ -56,12 +56,13 @@ function _stopCardTimer(card) {
   _activeCard = null;
 }
 
-function _updateCardTimerDisplay(card) {
+function _updateCardTimerDisplay(card, total) {
   var display = card.querySelector('.card-timer-display');
   if (!display) return;
-  var hours = Math.floor(_localSeconds / 3600);
-  var minutes = Math.floor((_localSeconds % 3600) / 60);
-  var seconds = _localSeconds % 60;
+  if (total === undefined) total = _localSeconds;
+  var hours = Math.floor(total / 3600);
+  var minutes = Math.floor((total % 3600) / 60);
+  var seconds = total % 60;
   var pad = function(n) { return n.toString().padStart(2, '0'); };
   if (hours > 0) {
     display.textContent = hours + ':' + pad(minutes) + ':' + pad(seconds);
@@ -83,27 +84,7 @@ function cardAddReps(btn, count) {
   var display = card.querySelector('.card-reps-count');
   if (display) display.textContent = totalReps;
 
-  fetch('/api/songs/' + songId + '/exercises/' + exerciseId, {
-    method: 'PATCH',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({
-      totalReps: totalReps,
-      lastPracticedAt: new Date().toISOString()
-    })
-  }).catch(console.error);
-
-  // Also log reps to daily log
-  var today = new Date().toISOString().slice(0, 10);
-  fetch('/api/songs/' + songId + '/daily-log', {
-    method: 'PATCH',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({
-      date: today,
-      exerciseId: exerciseId,
-      seconds: 0,
-      reps: count
-    })
-  }).catch(console.error);
+  queueSyncOp({ type: 'practice', songId: songId, exerciseId: exerciseId, reps: count });
 }
 
 function closePractice() {
@@ -115,37 +96,21 @@ function saveTimeIfNeeded() {
   if (_localSeconds > _lastSaveTime) saveTime();
 }
 
+// saveTime queues the seconds practiced since the last save. Only the delta
+// is sent, so time logged on other devices meanwhile is kept.
 function saveTime() {
   if (!_activeCard) return;
-  var songId = _activeCard.dataset.songId;
-  var exerciseId = _activeCard.dataset.exerciseId;
+  var card = _activeCard;
   var secondsDelta = _localSeconds - _lastSaveTime;
-  fetch('/api/songs/' + songId + '/exercises/' + exerciseId, {
-    method: 'PATCH',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({
-      totalPracticedSeconds: _localSeconds,
-      lastPracticedAt: new Date().toISOString()
-    })
-  }).then(function() {
-    _lastSaveTime = _localSeconds;
-    _activeCard.dataset.totalSeconds = _localSeconds;
-  }).catch(console.error);
-
-  // Also log to daily log
-  if (secondsDelta > 0) {
-    var today = new Date().toISOString().slice(0, 10);
-    fetch('/api/songs/' + songId + '/daily-log', {
-      method: 'PATCH',
-      headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({
-        date: today,
-        exerciseId: exerciseId,
-        seconds: secondsDelta,
-        reps: 0
-      })
-    }).catch(console.error);
-  }
+  if (secondsDelta <= 0) return;
+  _lastSaveTime = _localSeconds;
+  card.dataset.totalSeconds = _localSeconds;
+  queueSyncOp({
+    type: 'practice',
+    songId: card.dataset.songId,
+    exerciseId: card.dataset.exerciseId,
+    seconds: secondsDelta
+  });
 }
 
 // ===== Inactivity =====
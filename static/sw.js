@@ -0,0 +1,76 @@
+// ===== Service Worker =====
+// Keeps the app usable offline: pages are fetched network-first and fall back
+// to the last copy seen, while scripts, styles and crop previews are served
+// from the cache and refreshed in the background. Writes are not handled
+// here; practice changes wait in the sync queue (see app.js).
+var CACHE = 'avoidnt-v1';
+
+self.addEventListener('install', function() {
+  self.skipWaiting();
+});
+
+self.addEventListener('activate', function(event) {
+  event.waitUntil(
+    caches.keys().then(function(keys) {
+      return Promise.all(keys.filter(function(k) { return k !== CACHE; }).map(function(k) { return caches.delete(k); }));
+    }).then(function() { return self.clients.claim(); })
+  );
+});
+
+self.addEventListener('fetch', function(event) {
+  var req = event.request;
+  var url = new URL(req.url);
+  if (url.origin !== self.location.origin) return;
+
+  // Signing out must not leave the previous user's pages behind
+  if (req.method === 'POST' && url.pathname === '/logout') {
+    event.waitUntil(caches.delete(CACHE));
+    return;
+  }
+  if (req.method !== 'GET') return;
+
+  if (req.mode === 'navigate') {
+    event.respondWith(networkFirst(req));
+  } else if (url.pathname.startsWith('/static/') || isPreview(url.pathname)) {
+    event.respondWith(staleWhileRevalidate(event, req));
+  }
+  // Everything else, including /api/ data and the event stream, goes straight to the network
+});
+
+function isPreview(path) {
+  return /^\/api\/songs\/[^/]+\/preview\//.test(path) || /^\/api\/songs\/[^/]+\/exercises\/[^/]+\/image$/.test(path);
+}
+
+function networkFirst(req) {
+  return fetch(req).then(function(res) {
+    // Don't cache redirects to the login page or error pages
+    if (res.ok && !res.redirected) {
+      var copy = res.clone();
+      caches.open(CACHE).then(function(cache) { cache.put(req, copy); });
+    }
+    return res;
+  }).catch(function() {
+    return caches.match(req).then(function(cached) {
+      return cached || new Response('<h1>You are offline</h1><p>This page has not been opened on this device yet.</p>', {
+        status: 503,
+        headers: { 'Content-Type': 'text/html; charset=utf-8' }
+      });
+    });
+  });
+}
+
+function staleWhileRevalidate(event, req) {
+  return caches.open(CACHE).then(function(cache) {
+    return cache.match(req).then(function(cached) {
+      var fresh = fetch(req).then(function(res) {
+        if (res.ok) cache.put(req, res.clone());
+        return res;
+      });
+      if (cached) {
+        event.waitUntil(fresh.catch(function() {}));
+        return cached;
+      }
+      return fresh;
+    });
+  });
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// AICacheStore persists AI results as one JSON file per cache key.
+type AICacheStore struct {
+	root string
+	mu   sync.RWMutex
+}
+
+func NewAICacheStore(root string) *AICacheStore {
+	os.MkdirAll(root, 0o755)
+	return &AICacheStore{root: root}
+}
+
+func (s *AICacheStore) entryPath(key string) string {
+	return filepath.Join(s.root, key+".json")
+}
+
+// Get returns a cached entry, or nil if not found.
+func (s *AICacheStore) Get(key string) (*models.AICacheEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.entryPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.AICacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Put stores an entry, replacing any previous result for the same key.
+func (s *AICacheStore) Put(entry models.AICacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.entryPath(entry.Key), data, 0o644)
+}
+
+// List returns a user's cached entries, newest first. An empty jobID lists
+// all of them.
+func (s *AICacheStore) List(userID, jobID string) ([]models.AICacheEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []models.AICacheEntry
+	for _, e := range entries {
+		if e.UserID == userID && (jobID == "" || e.JobID == jobID) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt > out[j].CreatedAt
+	})
+	return out, nil
+}
+
+// Delete removes a single entry of a user by key. Other users' entries are
+// reported as not found.
+func (s *AICacheStore) Delete(userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.ContainsAny(key, `/\.`) {
+		return fmt.Errorf("invalid cache key")
+	}
+	data, err := os.ReadFile(s.entryPath(key))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cache entry not found")
+	}
+	if err != nil {
+		return err
+	}
+	var entry models.AICacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.UserID != userID {
+		return fmt.Errorf("cache entry not found")
+	}
+	return os.Remove(s.entryPath(key))
+}
+
+// Clear removes a user's entries for a job (or all of them if jobID is
+// empty) and returns how many were removed.
+func (s *AICacheStore) Clear(userID, jobID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readAll()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, e := range entries {
+		if e.UserID != userID || (jobID != "" && e.JobID != jobID) {
+			continue
+		}
+		if err := os.Remove(s.entryPath(e.Key)); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *AICacheStore) readAll() ([]models.AICacheEntry, error) {
+	files, err := os.ReadDir(s.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []models.AICacheEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.root, f.Name()))
+		if err != nil {
+			continue
+		}
+		var e models.AICacheEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			log.Printf("Warning: skipping AI cache entry %s: %v", f.Name(), err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
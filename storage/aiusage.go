@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// AIUsageStore is an append-only log of AI requests, one JSON entry per line.
+type AIUsageStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+func NewAIUsageStore(path string) *AIUsageStore {
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	return &AIUsageStore{path: path}
+}
+
+// Append adds an entry to the log.
+func (s *AIUsageStore) Append(entry models.AIUsageEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Since returns entries recorded at or after t, oldest first.
+func (s *AIUsageStore) Since(t time.Time) ([]models.AIUsageEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return []models.AIUsageEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []models.AIUsageEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e models.AIUsageEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // skip a torn line from an interrupted write
+		}
+		ts, err := time.Parse(time.RFC3339, e.Time)
+		if err != nil || ts.Before(t) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
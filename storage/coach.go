@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// CoachStore persists AI coach reports in each song's directory.
+type CoachStore struct {
+	root string
+	mu   sync.RWMutex
+}
+
+func NewCoachStore(root string) *CoachStore {
+	return &CoachStore{root: root}
+}
+
+func (s *CoachStore) reportsPath(songID string) string {
+	return filepath.Join(s.root, songID, "coach.json")
+}
+
+// GetAll returns a song's coach reports, newest first.
+func (s *CoachStore) GetAll(songID string) ([]models.CoachReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readReports(songID)
+}
+
+// Add stores a new report.
+func (s *CoachStore) Add(songID string, report models.CoachReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reports, err := s.readReports(songID)
+	if err != nil {
+		return err
+	}
+
+	reports = append([]models.CoachReport{report}, reports...)
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.reportsPath(songID), data, 0o644)
+}
+
+func (s *CoachStore) readReports(songID string) ([]models.CoachReport, error) {
+	data, err := os.ReadFile(s.reportsPath(songID))
+	if os.IsNotExist(err) {
+		return []models.CoachReport{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []models.CoachReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
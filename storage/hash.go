@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileHashes memoizes content hashes by path, invalidated on size or mtime change.
+var fileHashes = struct {
+	sync.Mutex
+	m map[string]fileHashEntry
+}{m: map[string]fileHashEntry{}}
+
+type fileHashEntry struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// HashBytes returns the content hash used for ETags and versioned URLs.
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// FileHash returns the content hash of a file, reusing a cached value when
+// the file's size and modification time are unchanged.
+func FileHash(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	fileHashes.Lock()
+	e, ok := fileHashes.m[path]
+	fileHashes.Unlock()
+	if ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.hash, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := h.Sum(nil)
+	hash := hex.EncodeToString(sum[:16])
+
+	fileHashes.Lock()
+	fileHashes.m[path] = fileHashEntry{size: info.Size(), modTime: info.ModTime(), hash: hash}
+	fileHashes.Unlock()
+	return hash, nil
+}
@@ -1,16 +1,21 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 )
 
-// JobStore manages PDF conversion job output directories.
+// JobStore manages PDF conversion job output directories. Jobs are shared
+// storage; each job directory's access.json lists the users who may read it.
 type JobStore struct {
 	root string
+	mu   sync.Mutex // guards access.json files
 }
 
 func NewJobStore(root string) *JobStore {
@@ -24,6 +29,47 @@ func (j *JobStore) CreateJobDir(jobID string) (string, error) {
 	return dir, os.MkdirAll(dir, 0o755)
 }
 
+// accessPath returns the path of a job's list of users allowed to read it.
+func (j *JobStore) accessPath(jobID string) string {
+	return filepath.Join(j.root, jobID, "access.json")
+}
+
+// HasAccess reports whether userID has been granted access to a job.
+func (j *JobStore) HasAccess(jobID, userID string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Contains(j.readAccess(jobID), userID)
+}
+
+// Grant lets userID read an existing job's pages.
+func (j *JobStore) Grant(jobID, userID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(j.root, jobID)); err != nil {
+		return err
+	}
+	users := j.readAccess(jobID)
+	if slices.Contains(users, userID) {
+		return nil
+	}
+	data, err := json.Marshal(append(users, userID))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(j.accessPath(jobID), data, 0o644)
+}
+
+func (j *JobStore) readAccess(jobID string) []string {
+	data, err := os.ReadFile(j.accessPath(jobID))
+	if err != nil {
+		return nil
+	}
+	var users []string
+	json.Unmarshal(data, &users)
+	return users
+}
+
 // GetPagePath returns the path to a page image (prefers JPEG, falls back to PNG).
 func (j *JobStore) GetPagePath(jobID string, pageNum int) (string, error) {
 	dir := filepath.Join(j.root, jobID)
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// Library is one user's songs, settings and practice logs.
+type Library struct {
+	Songs     *SongStore
+	Settings  *SettingsStore
+	DailyLogs *DailyLogStore
+	StageLogs *StageLogStore
+	Coach     *CoachStore
+	SyncOps   *SyncOpStore
+}
+
+// NewLibrary opens the stores for a songs directory and settings file.
+func NewLibrary(songsPath, settingsPath string) *Library {
+	return &Library{
+		Songs:     NewSongStore(songsPath),
+		Settings:  NewSettingsStore(settingsPath),
+		DailyLogs: NewDailyLogStore(songsPath),
+		StageLogs: NewStageLogStore(songsPath),
+		Coach:     NewCoachStore(songsPath),
+		SyncOps:   NewSyncOpStore(filepath.Join(filepath.Dir(settingsPath), "sync-ops")),
+	}
+}
+
+// Libraries hands out each user's Library. Stores are created once per user
+// and reused, so their locks cover every request for that user.
+type Libraries struct {
+	root           string // per-user directories live in {root}/{userId}
+	legacySongs    string
+	legacySettings string
+
+	mu   sync.Mutex
+	libs map[string]*Library
+}
+
+func NewLibraries(root, legacySongs, legacySettings string) *Libraries {
+	return &Libraries{
+		root:           root,
+		legacySongs:    legacySongs,
+		legacySettings: legacySettings,
+		libs:           map[string]*Library{},
+	}
+}
+
+// For returns the library owned by a user.
+func (l *Libraries) For(user *models.User) *Library {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lib, ok := l.libs[user.ID]; ok {
+		return lib
+	}
+	var lib *Library
+	if user.Legacy {
+		lib = NewLibrary(l.legacySongs, l.legacySettings)
+	} else {
+		dir := filepath.Join(l.root, user.ID)
+		lib = NewLibrary(filepath.Join(dir, "songs"), filepath.Join(dir, "settings.json"))
+	}
+	lib.Settings.DefaultDisplayName = user.Username
+	l.libs[user.ID] = lib
+	return lib
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// SessionStore persists sign-in sessions in a single JSON file. Tokens are
+// handed to the browser once and only their SHA-256 is kept on disk.
+type SessionStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+func NewSessionStore(path string) *SessionStore {
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	return &SessionStore{path: path}
+}
+
+// newToken returns a random URL-safe token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *SessionStore) load() ([]models.Session, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return []models.Session{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []models.Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// save writes sessions back, dropping any that have expired.
+func (s *SessionStore) save(sessions []models.Session) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	live := sessions[:0]
+	for _, sess := range sessions {
+		if sess.ExpiresAt > now {
+			live = append(live, sess)
+		}
+	}
+	data, err := json.MarshalIndent(live, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// Create starts a session for a user and returns its cookie token.
+func (s *SessionStore) Create(userID string, ttl time.Duration) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	sessions = append(sessions, models.Session{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: expires.Format(time.RFC3339),
+	})
+	if err := s.save(sessions); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// Lookup returns the live session for a token, or nil if it is unknown or expired.
+func (s *SessionStore) Lookup(token string) (*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	h := hashToken(token)
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := range sessions {
+		if sessions[i].TokenHash == h && sessions[i].ExpiresAt > now {
+			return &sessions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Delete ends the session for a token. Unknown tokens are ignored.
+func (s *SessionStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return err
+	}
+	h := hashToken(token)
+	kept := sessions[:0]
+	for _, sess := range sessions {
+		if sess.TokenHash != h {
+			kept = append(kept, sess)
+		}
+	}
+	return s.save(kept)
+}
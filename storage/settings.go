@@ -14,6 +14,9 @@ import (
 type SettingsStore struct {
 	path string
 	mu   sync.RWMutex
+
+	// DefaultDisplayName is shown when no display name has been saved.
+	DefaultDisplayName string
 }
 
 func NewSettingsStore(path string) *SettingsStore {
@@ -31,13 +34,13 @@ func (s *SettingsStore) Get() models.UserSettings {
 		if !os.IsNotExist(err) {
 			log.Printf("Warning: could not read settings: %v", err)
 		}
-		return models.DefaultSettings()
+		return s.defaults()
 	}
 
 	var settings models.UserSettings
 	if err := json.Unmarshal(data, &settings); err != nil {
 		log.Printf("Warning: invalid settings JSON: %v", err)
-		return models.DefaultSettings()
+		return s.defaults()
 	}
 
 	// Ensure stageNames has exactly 5 entries
@@ -48,12 +51,18 @@ func (s *SettingsStore) Get() models.UserSettings {
 		settings.Theme = "light"
 	}
 	if settings.DisplayName == "" {
-		settings.DisplayName = models.DefaultSettings().DisplayName
+		settings.DisplayName = s.DefaultDisplayName
 	}
 
 	return settings
 }
 
+func (s *SettingsStore) defaults() models.UserSettings {
+	settings := models.DefaultSettings()
+	settings.DisplayName = s.DefaultDisplayName
+	return settings
+}
+
 // Save persists user settings to disk.
 func (s *SettingsStore) Save(settings models.UserSettings) error {
 	s.mu.Lock()
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// Share links live next to the song in shares.json, so deleting the song
+// revokes them and CopyTo never carries them over.
+
+func (s *SongStore) sharesPath(songID string) string {
+	return filepath.Join(s.songDir(songID), "shares.json")
+}
+
+func (s *SongStore) loadShareLinks(songID string) ([]models.ShareLink, error) {
+	data, err := os.ReadFile(s.sharesPath(songID))
+	if os.IsNotExist(err) {
+		return []models.ShareLink{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []models.ShareLink
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (s *SongStore) saveShareLinks(songID string, links []models.ShareLink) error {
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.sharesPath(songID), data, 0o644)
+}
+
+// ListShareLinks returns a song's public share links, oldest first.
+func (s *SongStore) ListShareLinks(songID string) ([]models.ShareLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadShareLinks(songID)
+}
+
+// AddShareLink stores a new share link for an existing song.
+func (s *SongStore) AddShareLink(songID string, link models.ShareLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(s.songDir(songID), "song.json")); err != nil {
+		return fmt.Errorf("song not found")
+	}
+	links, err := s.loadShareLinks(songID)
+	if err != nil {
+		return err
+	}
+	return s.saveShareLinks(songID, append(links, link))
+}
+
+// DeleteShareLink revokes a share link.
+func (s *SongStore) DeleteShareLink(songID, linkID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.loadShareLinks(songID)
+	if err != nil {
+		return err
+	}
+	for i, l := range links {
+		if l.ID == linkID {
+			return s.saveShareLinks(songID, append(links[:i], links[i+1:]...))
+		}
+	}
+	return fmt.Errorf("share link not found")
+}
+
+// RecordShareAccess counts a view of a share link and returns the updated
+// link, or nil if it doesn't exist. Expired links are returned unchanged.
+func (s *SongStore) RecordShareAccess(songID, linkID string, now time.Time) (*models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.loadShareLinks(songID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		l := &links[i]
+		if l.ID != linkID {
+			continue
+		}
+		if l.Expired(now) {
+			return l, nil
+		}
+		ts := now.UTC().Format(time.RFC3339)
+		l.AccessCount++
+		l.LastAccessedAt = &ts
+		if err := s.saveShareLinks(songID, links); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// GetShareLink returns a share link without counting an access, or nil.
+func (s *SongStore) GetShareLink(songID, linkID string) (*models.ShareLink, error) {
+	links, err := s.ListShareLinks(songID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		if links[i].ID == linkID {
+			return &links[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// LoadOrCreateSecret reads a server signing key from path, generating and
+// saving a random 32-byte key on first use.
+func LoadOrCreateSecret(path string) ([]byte, error) {
+	if key, err := os.ReadFile(path); err == nil && len(key) >= 32 {
+		return key, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -3,11 +3,14 @@ package storage
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/LianHaeming/avoidnt/models"
@@ -28,11 +31,26 @@ func (s *SongStore) songDir(id string) string {
 	return filepath.Join(s.root, id)
 }
 
+// ErrSongNotFound is returned by Update for a song that doesn't exist.
+var ErrSongNotFound = errors.New("song not found")
+
+// ErrInvalidID is returned when asked to write a song whose song, exercise
+// or crop ID is not a models.ValidID. Handlers check IDs first; this keeps a
+// missed check from turning into a path outside the library.
+var ErrInvalidID = errors.New("invalid ID")
+
 // Get returns a song by ID, or nil if not found.
 func (s *SongStore) Get(id string) (*models.Song, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.read(id)
+}
 
+// read loads a song without locking; callers hold s.mu.
+func (s *SongStore) read(id string) (*models.Song, error) {
+	if !models.ValidID(id) {
+		return nil, nil
+	}
 	path := filepath.Join(s.songDir(id), "song.json")
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
@@ -49,6 +67,7 @@ func (s *SongStore) Get(id string) (*models.Song, error) {
 
 	// Migration: handle old format fields
 	migrateSong(&song)
+	s.fillPreviewHashes(&song)
 
 	return &song, nil
 }
@@ -93,29 +112,120 @@ func (s *SongStore) ListAll() ([]models.Song, error) {
 	return songs, nil
 }
 
-// Save persists a song and its preview images.
+// Save persists a song and its preview images, replacing any stored version.
+// Prefer Update or Put when the new state depends on the stored one.
 func (s *SongStore) Save(song *models.Song) error {
+	return s.Put(song, nil)
+}
+
+// Put replaces a song. merge, if set, runs under the store lock with the
+// stored version (nil for a new song) so it can carry fields over without
+// racing other writers. An error from merge aborts the write.
+func (s *SongStore) Put(song *models.Song, merge func(existing *models.Song) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.read(song.ID)
+	if err != nil {
+		return err
+	}
+	if merge != nil {
+		if err := merge(existing); err != nil {
+			return err
+		}
+	}
+	song.Revision = 1
+	if existing != nil {
+		song.Revision = existing.Revision + 1
+	}
+	return s.write(song)
+}
+
+// Update applies fn to the stored song and saves the result as one atomic
+// read-modify-write. It returns ErrSongNotFound for a missing song; an error
+// from fn aborts the write and is returned as is.
+func (s *SongStore) Update(id string, fn func(song *models.Song) error) (*models.Song, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	song, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if song == nil {
+		return nil, ErrSongNotFound
+	}
+	if err := fn(song); err != nil {
+		return nil, err
+	}
+	song.Revision++
+	if err := s.write(song); err != nil {
+		return nil, err
+	}
+	return song, nil
+}
+
+// SetPreviewHashes records regenerated preview hashes by crop ID. Hashes are
+// derived from the preview files, so this doesn't bump the song's revision.
+func (s *SongStore) SetPreviewHashes(songID string, hashes map[string]string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	song, err := s.read(songID)
+	if err != nil || song == nil {
+		return err
+	}
+	changed := false
+	for i := range song.Exercises {
+		for j := range song.Exercises[i].Crops {
+			crop := &song.Exercises[i].Crops[j]
+			if h, ok := hashes[crop.ID]; ok && crop.PreviewHash != h {
+				crop.PreviewHash = h
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.write(song)
+}
+
+// write persists a song and its preview images; callers hold s.mu.
+func (s *SongStore) write(song *models.Song) error {
+	if models.InvalidSongID(song) != "" {
+		return ErrInvalidID
+	}
 	dir := s.songDir(song.ID)
 	os.MkdirAll(dir, 0o755)
 
 	// Extract and save preview images, strip base64 from stored JSON
 	for i := range song.Exercises {
+		changed := false
 		for j := range song.Exercises[i].Crops {
 			crop := &song.Exercises[i].Crops[j]
 			if crop.PreviewBase64 != nil && *crop.PreviewBase64 != "" {
 				decoded, err := base64.StdEncoding.DecodeString(*crop.PreviewBase64)
 				if err == nil {
 					previewPath := filepath.Join(dir, fmt.Sprintf("preview_%s.png", crop.ID))
-					os.WriteFile(previewPath, decoded, 0o644)
+					if os.WriteFile(previewPath, decoded, 0o644) == nil {
+						crop.PreviewHash = HashBytes(decoded)
+						s.clearPreviewVariants(song.ID, crop.ID)
+						changed = true
+					}
 				}
 				crop.PreviewBase64 = nil
 			}
 		}
+		// Stitched images are stale once any of their crops are rewritten
+		if changed {
+			s.clearExerciseImages(song.ID, song.Exercises[i].ID)
+		}
 	}
 
+	// Clients don't send preview hashes back, so recompute any that are missing
+	s.fillPreviewHashes(song)
+
 	data, err := json.MarshalIndent(song, "", "  ")
 	if err != nil {
 		return err
@@ -124,12 +234,72 @@ func (s *SongStore) Save(song *models.Song) error {
 	return os.WriteFile(filepath.Join(dir, "song.json"), data, 0o644)
 }
 
+// CopyTo saves song into dst together with the preview and stitched exercise
+// images of song srcID in s. Practice logs and coach reports are not copied.
+// The caller sets the copy's ID; crop and exercise IDs must be unchanged.
+func (s *SongStore) CopyTo(dst *SongStore, srcID string, song *models.Song) error {
+	if dst == s {
+		return fmt.Errorf("cannot copy a song within the same library")
+	}
+	if !models.ValidID(srcID) || models.InvalidSongID(song) != "" {
+		return ErrInvalidID
+	}
+	// Read under the source lock and write under the destination lock, never
+	// both: two teachers assigning to each other at once would deadlock.
+	files, err := s.readImages(srcID)
+	if err != nil {
+		return err
+	}
+
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+
+	dstDir := dst.songDir(song.ID)
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dstDir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	song.Revision = 1
+	data, err := json.MarshalIndent(song, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dstDir, "song.json"), data, 0o644)
+}
+
+// readImages returns a song's preview and stitched exercise images by file name.
+func (s *SongStore) readImages(id string) (map[string][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := map[string][]byte{}
+	for _, pattern := range []string{"preview_*", "exercise_*.png"} {
+		matches, _ := filepath.Glob(filepath.Join(s.songDir(id), pattern))
+		for _, m := range matches {
+			data, err := os.ReadFile(m)
+			if err != nil {
+				return nil, err
+			}
+			files[filepath.Base(m)] = data
+		}
+	}
+	return files, nil
+}
+
 // Delete removes a song directory.
 func (s *SongStore) Delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	dir := s.songDir(id)
+	if !models.ValidID(id) {
+		return fmt.Errorf("song not found")
+	}
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		return fmt.Errorf("song not found")
 	}
@@ -151,6 +321,144 @@ func (s *SongStore) PreviewPath(songID, cropID string) string {
 	return filepath.Join(s.songDir(songID), fmt.Sprintf("preview_%s.png", cropID))
 }
 
+// PreviewVariantPath returns the path of a resized/re-encoded preview variant.
+// Width 0 denotes a full-size variant.
+func (s *SongStore) PreviewVariantPath(songID, cropID string, width int, ext string) string {
+	size := "full"
+	if width > 0 {
+		size = fmt.Sprintf("w%d", width)
+	}
+	return filepath.Join(s.songDir(songID), fmt.Sprintf("preview_%s_%s.%s", cropID, size, ext))
+}
+
+// ErrCropNotFound is returned by ReplacePreview when the song or crop is gone,
+// or the crop was moved to another region.
+var ErrCropNotFound = errors.New("crop not found")
+
+// ReplacePreview renames a regenerated master preview for crop, built in tmp,
+// over the stored one and clears its variants and its exercise's stitched
+// images. Under the store lock it first checks that the song still has the
+// crop with the same page and region; if not, tmp is removed and
+// ErrCropNotFound returned, so a deleted song is not recreated on disk.
+func (s *SongStore) ReplacePreview(songID string, crop models.Crop, tmp string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	song, err := s.read(songID)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	var exerciseID string
+	if song != nil {
+		for _, ex := range song.Exercises {
+			for _, c := range ex.Crops {
+				if c.ID == crop.ID && c.PageIndex == crop.PageIndex && c.Rect == crop.Rect {
+					exerciseID = ex.ID
+				}
+			}
+		}
+	}
+	if exerciseID == "" {
+		os.Remove(tmp)
+		return ErrCropNotFound
+	}
+
+	if err := os.Rename(tmp, s.PreviewPath(songID, crop.ID)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.clearPreviewVariants(songID, crop.ID)
+	s.clearExerciseImages(songID, exerciseID)
+	return nil
+}
+
+// ErrStalePreview is returned by CommitPreviewVariant when the crop's master
+// preview changed while the variant was being built.
+var ErrStalePreview = errors.New("preview changed while building variant")
+
+// CommitPreviewVariant renames a variant built in tmp to path, unless the
+// crop's master no longer has masterHash, the hash of the master the variant
+// was built from; then tmp is removed and ErrStalePreview returned. It runs
+// under the store lock, like the writes that replace masters and clear their
+// variants, so an old variant can't land after a newer master.
+func (s *SongStore) CommitPreviewVariant(songID, cropID, tmp, path, masterHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, err := FileHash(s.PreviewPath(songID, cropID)); err != nil || h != masterHash {
+		os.Remove(tmp)
+		return ErrStalePreview
+	}
+	return os.Rename(tmp, path)
+}
+
+// clearPreviewVariants removes all variants derived from a crop's PNG
+// master; callers hold s.mu.
+func (s *SongStore) clearPreviewVariants(songID, cropID string) {
+	s.removeDerived(songID, "preview_"+cropID+"_", variantSuffix)
+}
+
+// Suffixes of derived image names after "preview_{cropId}_" and
+// "exercise_{exerciseId}_". IDs may contain "_", so a prefix alone would also
+// match the files of crop "a_b" when clearing crop "a".
+var (
+	variantSuffix  = regexp.MustCompile(`^(full|w[0-9]+)\.(jpg|webp)$`)
+	exerciseSuffix = regexp.MustCompile(`^[0-9a-f]+\.png$`)
+)
+
+// removeDerived deletes the files of a song named prefix followed by a
+// suffix matching pattern; callers hold s.mu.
+func (s *SongStore) removeDerived(songID, prefix string, pattern *regexp.Regexp) {
+	files, _ := os.ReadDir(s.songDir(songID))
+	for _, f := range files {
+		rest, ok := strings.CutPrefix(f.Name(), prefix)
+		if ok && pattern.MatchString(rest) {
+			os.Remove(filepath.Join(s.songDir(songID), f.Name()))
+		}
+	}
+}
+
+// RefreshPreviewHash recomputes a crop's preview hash after its file was rewritten.
+func (s *SongStore) RefreshPreviewHash(songID string, crop *models.Crop) {
+	crop.PreviewHash = ""
+	if hash, err := FileHash(s.PreviewPath(songID, crop.ID)); err == nil {
+		crop.PreviewHash = hash
+	}
+}
+
+// fillPreviewHashes sets PreviewHash on crops that lack one and have a preview file.
+func (s *SongStore) fillPreviewHashes(song *models.Song) {
+	for i := range song.Exercises {
+		for j := range song.Exercises[i].Crops {
+			crop := &song.Exercises[i].Crops[j]
+			if crop.PreviewHash != "" {
+				continue
+			}
+			if hash, err := FileHash(s.PreviewPath(song.ID, crop.ID)); err == nil {
+				crop.PreviewHash = hash
+			}
+		}
+	}
+}
+
+// ExerciseImagePath returns the cache path for an exercise's stitched image.
+// The key identifies the crop layout and rendering options it was built from.
+func (s *SongStore) ExerciseImagePath(songID, exerciseID, key string) string {
+	return filepath.Join(s.songDir(songID), fmt.Sprintf("exercise_%s_%s.png", exerciseID, key))
+}
+
+// ClearExerciseImages removes all cached stitched images for an exercise.
+func (s *SongStore) ClearExerciseImages(songID, exerciseID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clearExerciseImages(songID, exerciseID)
+}
+
+func (s *SongStore) clearExerciseImages(songID, exerciseID string) {
+	s.removeDerived(songID, "exercise_"+exerciseID+"_", exerciseSuffix)
+}
+
 // migrateSong handles old data format migration.
 func migrateSong(song *models.Song) {
 	if song.Structure == nil {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/LianHaeming/avoidnt/models"
@@ -45,6 +46,25 @@ func (s *StageLogStore) Append(songID string, entry models.StageLogEntry) error
 	return s.writeLogs(songID, logs)
 }
 
+// Insert adds a stage change entry in timestamp order, for changes that
+// reach the server after later ones (e.g. synced from an offline device).
+func (s *StageLogStore) Insert(songID string, entry models.StageLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logs, err := s.readLogs(songID)
+	if err != nil {
+		return err
+	}
+
+	i := len(logs)
+	for i > 0 && logs[i-1].Timestamp > entry.Timestamp {
+		i--
+	}
+	logs = slices.Insert(logs, i, entry)
+	return s.writeLogs(songID, logs)
+}
+
 // HasLogs checks if a stage log file exists for a song.
 func (s *StageLogStore) HasLogs(songID string) bool {
 	s.mu.RLock()
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncOpRetention is how long applied sync operation IDs are remembered.
+// Clients must not replay operations older than this.
+const SyncOpRetention = 90 * 24 * time.Hour
+
+// SyncOpPending is the status of an operation whose change was started but
+// whose outcome was never recorded. It is treated as handled: counting a
+// practice delta twice is worse than losing it.
+const SyncOpPending = "pending"
+
+// syncOpRetry marks an operation that failed without changing anything, so
+// its ID may be sent again.
+const syncOpRetry = "retry"
+
+// SyncOpRecord is the outcome of a client operation that was already handled.
+type SyncOpRecord struct {
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint,omitempty"` // what the operation did, to spot reused IDs
+	HandledAt   string `json:"handledAt"`             // RFC 3339
+}
+
+// syncOpLine is one line of a day's log; the last line for an ID wins.
+type syncOpLine struct {
+	ID string `json:"id"`
+	SyncOpRecord
+}
+
+// SyncOpStore remembers which client operations (offline-queue entries and
+// Idempotency-Key requests) a user's devices have already sent, so a retried
+// request is not applied twice. Records are appended to one JSON-lines file
+// per day ({dir}/2006-01-02.jsonl); files past the retention window are
+// deleted whole. The IDs are kept in memory once loaded.
+type SyncOpStore struct {
+	dir string
+	mu  sync.Mutex
+	ops map[string]SyncOpRecord // nil until loaded
+	day string                  // day the files were last pruned
+}
+
+func NewSyncOpStore(dir string) *SyncOpStore {
+	return &SyncOpStore{dir: dir}
+}
+
+// Apply runs fn for an operation unless its ID was handled before, in which
+// case the earlier record is returned with dup set; callers compare its
+// Fingerprint to detect an ID reused for a different operation. The ID is
+// saved as pending before fn runs. fn returns the status to remember; when
+// it fails the ID is released so the client can retry. Operations of one
+// user are applied one at a time.
+func (s *SyncOpStore) Apply(id, fingerprint string, now time.Time, fn func() (string, error)) (rec SyncOpRecord, dup bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(now); err != nil {
+		return SyncOpRecord{}, false, err
+	}
+	if prev, ok := s.ops[id]; ok {
+		return prev, true, nil
+	}
+
+	rec = SyncOpRecord{Status: SyncOpPending, Fingerprint: fingerprint, HandledAt: now.UTC().Format(time.RFC3339)}
+	if err := s.append(now, id, rec); err != nil {
+		return SyncOpRecord{}, false, err
+	}
+	s.ops[id] = rec
+
+	status, err := fn()
+	if err != nil {
+		// Nothing was changed. If the release can't be saved either, the ID
+		// stays pending and a retry is reported as a duplicate.
+		if werr := s.append(now, id, SyncOpRecord{Status: syncOpRetry, HandledAt: rec.HandledAt}); werr != nil {
+			log.Printf("Failed to release sync op %s: %v", id, werr)
+			return SyncOpRecord{}, false, err
+		}
+		delete(s.ops, id)
+		return SyncOpRecord{}, false, err
+	}
+
+	// The change is saved; a missing final line only leaves the ID pending
+	rec.Status = status
+	s.ops[id] = rec
+	if err := s.append(now, id, rec); err != nil {
+		log.Printf("Failed to record sync op %s: %v", id, err)
+	}
+	return rec, false, nil
+}
+
+// load reads the IDs of the retention window on first use, and deletes the
+// day files that have expired whenever the date changes.
+func (s *SyncOpStore) load(now time.Time) error {
+	today := now.UTC().Format("2006-01-02")
+	if s.ops != nil && s.day == today {
+		return nil
+	}
+	cutoff := now.Add(-SyncOpRetention).UTC().Format("2006-01-02")
+	if s.ops == nil {
+		if err := s.migrate(cutoff); err != nil {
+			return err
+		}
+	}
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var days []string
+	for _, f := range files {
+		day, ok := strings.CutSuffix(f.Name(), ".jsonl")
+		if !ok || f.IsDir() {
+			continue
+		}
+		if day < cutoff {
+			os.Remove(filepath.Join(s.dir, f.Name()))
+			continue
+		}
+		days = append(days, day)
+	}
+
+	if s.ops == nil {
+		sort.Strings(days)
+		ops := map[string]SyncOpRecord{}
+		for _, day := range days {
+			if err := readSyncOps(filepath.Join(s.dir, day+".jsonl"), ops); err != nil {
+				return err
+			}
+		}
+		s.ops = ops
+	} else {
+		for id, rec := range s.ops {
+			if rec.HandledAt < cutoff { // compares the date part
+				delete(s.ops, id)
+			}
+		}
+	}
+	s.day = today
+	return nil
+}
+
+// migrate moves records from the single JSON map used before the day files
+// ({dir}.json) into them.
+func (s *SyncOpStore) migrate(cutoff string) error {
+	legacy := s.dir + ".json"
+	data, err := os.ReadFile(legacy)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	old := map[string]SyncOpRecord{}
+	if err := json.Unmarshal(data, &old); err != nil {
+		return err
+	}
+	for id, rec := range old {
+		t, err := time.Parse(time.RFC3339, rec.HandledAt)
+		if err != nil || rec.HandledAt < cutoff {
+			continue
+		}
+		if err := s.append(t, id, rec); err != nil {
+			return err
+		}
+	}
+	return os.Remove(legacy)
+}
+
+// readSyncOps adds the records of one day's file to ops.
+func readSyncOps(path string, ops map[string]SyncOpRecord) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line syncOpLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.ID == "" {
+			continue // skip a torn line from an interrupted write
+		}
+		if line.Status == syncOpRetry {
+			delete(ops, line.ID)
+		} else {
+			ops[line.ID] = line.SyncOpRecord
+		}
+	}
+	return scanner.Err()
+}
+
+// append adds a record to the file of the day it was handled.
+func (s *SyncOpStore) append(now time.Time, id string, rec SyncOpRecord) error {
+	data, err := json.Marshal(syncOpLine{ID: id, SyncOpRecord: rec})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, now.UTC().Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
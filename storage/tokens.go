@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// APITokenPrefix starts every personal API token, so they are easy to spot
+// in scripts and secret scanners.
+const APITokenPrefix = "avd_"
+
+// TokenStore persists personal API tokens in a single JSON file.
+type TokenStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+func NewTokenStore(path string) *TokenStore {
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	return &TokenStore{path: path}
+}
+
+func (s *TokenStore) load() ([]models.APIToken, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return []models.APIToken{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []models.APIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *TokenStore) save(tokens []models.APIToken) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// Create issues a new token for a user. The plaintext token is returned once
+// and cannot be recovered later.
+func (s *TokenStore) Create(userID, id, name string) (string, *models.APIToken, error) {
+	secret, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := APITokenPrefix + secret
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return "", nil, err
+	}
+	t := models.APIToken{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(APITokenPrefix)+6],
+		TokenHash: hashToken(token),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	tokens = append(tokens, t)
+	if err := s.save(tokens); err != nil {
+		return "", nil, err
+	}
+	return token, &t, nil
+}
+
+// List returns a user's tokens, oldest first.
+func (s *TokenStore) List(userID string) ([]models.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	mine := []models.APIToken{}
+	for _, t := range tokens {
+		if t.UserID == userID {
+			mine = append(mine, t)
+		}
+	}
+	return mine, nil
+}
+
+// Delete revokes one of a user's tokens.
+func (s *TokenStore) Delete(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if t.ID == id && t.UserID == userID {
+			return s.save(append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return fmt.Errorf("token not found")
+}
+
+// Lookup returns the token record for a plaintext token, or nil if it is
+// unknown or revoked. LastUsedAt is refreshed at most once an hour.
+func (s *TokenStore) Lookup(token string) (*models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	h := hashToken(token)
+	for i := range tokens {
+		t := &tokens[i]
+		if t.TokenHash != h {
+			continue
+		}
+		now := time.Now().UTC()
+		if t.LastUsedAt == nil || stale(*t.LastUsedAt, now, time.Hour) {
+			used := now.Format(time.RFC3339)
+			t.LastUsedAt = &used
+			if err := s.save(tokens); err != nil {
+				return nil, err
+			}
+		}
+		found := *t
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func stale(ts string, now time.Time, age time.Duration) bool {
+	t, err := time.Parse(time.RFC3339, ts)
+	return err != nil || now.Sub(t) >= age
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LianHaeming/avoidnt/models"
+)
+
+// ErrUsernameTaken is returned by UserStore.Create for a duplicate username.
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// UserStore persists accounts in a single JSON file.
+type UserStore struct {
+	path string
+	mu   sync.RWMutex
+}
+
+func NewUserStore(path string) *UserStore {
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	return &UserStore{path: path}
+}
+
+func (s *UserStore) load() ([]models.User, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return []models.User{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Get returns a user by ID, or nil if not found.
+func (s *UserStore) Get(id string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].ID == id {
+			return &users[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByUsername returns a user by username (case-insensitive), or nil if not found.
+func (s *UserStore) GetByUsername(username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if strings.EqualFold(users[i].Username, username) {
+			return &users[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Count returns the number of accounts.
+func (s *UserStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, err := s.load()
+	return len(users), err
+}
+
+// List returns all accounts.
+func (s *UserStore) List() ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.load()
+}
+
+// StudentsOf returns the accounts that list teacherID among their teachers.
+func (s *UserStore) StudentsOf(teacherID string) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var students []models.User
+	for _, u := range users {
+		if u.HasTeacher(teacherID) {
+			students = append(students, u)
+		}
+	}
+	sort.Slice(students, func(i, j int) bool {
+		return students[i].Username < students[j].Username
+	})
+	return students, nil
+}
+
+// Update applies fn to a user and saves the result atomically.
+func (s *UserStore) Update(id string, fn func(*models.User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if users[i].ID == id {
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+			return s.save(users)
+		}
+	}
+	return fmt.Errorf("user not found")
+}
+
+func (s *UserStore) save(users []models.User) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// Create adds a new account. The first account ever created adopts the
+// legacy library (see models.User.Legacy).
+func (s *UserStore) Create(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.load()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if strings.EqualFold(u.Username, user.Username) {
+			return ErrUsernameTaken
+		}
+	}
+	user.Legacy = len(users) == 0
+	users = append(users, *user)
+	return s.save(users)
+}
@@ -5,28 +5,110 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"math"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Templates holds all page templates, keyed by page name.
 type Templates struct {
+	fsys     fs.FS
+	assetVer string
+	live     bool // re-parse when a file changes (development)
+
+	mu    sync.Mutex
 	pages map[string]*template.Template
+	stamp string // file names, sizes and mod times at the last parse
+}
+
+// Load parses all templates from fsys, which holds layout.html, the page
+// templates and partials/.
+func Load(fsys fs.FS, assetVer string) (*Templates, error) {
+	t := &Templates{fsys: fsys, assetVer: assetVer}
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadLive is Load for development: templates are parsed again whenever a
+// file in fsys has changed since the last render.
+func LoadLive(fsys fs.FS, assetVer string) (*Templates, error) {
+	t, err := Load(fsys, assetVer)
+	if err != nil {
+		return nil, err
+	}
+	t.live = true
+	return t, nil
+}
+
+// current returns the parsed pages, re-parsing them first if live and stale.
+func (t *Templates) current() (map[string]*template.Template, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.live {
+		stamp, err := fsStamp(t.fsys)
+		if err != nil {
+			return nil, err
+		}
+		if stamp != t.stamp {
+			if err := t.reload(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t.pages, nil
+}
+
+// reload parses every template and records the files' stamp.
+func (t *Templates) reload() error {
+	stamp, err := fsStamp(t.fsys)
+	if err != nil {
+		return err
+	}
+	pages, err := parse(t.fsys, t.assetVer)
+	if err != nil {
+		return err
+	}
+	t.pages, t.stamp = pages, stamp
+	return nil
+}
+
+// fsStamp summarizes the name, size and mod time of every file in fsys.
+func fsStamp(fsys fs.FS) (string, error) {
+	var b strings.Builder
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String(), err
 }
 
 // ExecuteTemplate renders a page template by name.
 func (t *Templates) ExecuteTemplate(w io.Writer, name string, data any) error {
-	tmpl, ok := t.pages[name]
+	pages, err := t.current()
+	if err != nil {
+		return err
+	}
+	tmpl, ok := pages[name]
 	if !ok {
 		return fmt.Errorf("template %q not found", name)
 	}
 	// Partials are rendered directly by their define name, pages via layout
 	if strings.HasPrefix(name, "partials/") {
 		// The partial defines a named template; execute the first defined name
-		baseName := strings.TrimSuffix(filepath.Base(name), ".html") + "-inner"
+		baseName := strings.TrimSuffix(path.Base(name), ".html") + "-inner"
 		// Try common patterns
 		for _, try := range []string{"song-rows-inner", baseName} {
 			if tmpl.Lookup(try) != nil {
@@ -39,9 +121,9 @@ func (t *Templates) ExecuteTemplate(w io.Writer, name string, data any) error {
 	return tmpl.ExecuteTemplate(w, "layout", data)
 }
 
-// Load parses all templates. Each page template gets its own clone of the
+// parse parses all templates. Each page template gets its own clone of the
 // shared templates (layout + partials) so {{define "content"}} doesn't collide.
-func Load(assetVer string) *Templates {
+func parse(fsys fs.FS, assetVer string) (map[string]*template.Template, error) {
 	funcMap := template.FuncMap{
 		// Cache-busting version string for static assets
 		"assetVer": func() string { return assetVer },
@@ -127,41 +209,47 @@ func Load(assetVer string) *Templates {
 	}
 
 	// Parse shared templates (layout + partials) as the base.
-	base := template.Must(
-		template.New("base").Funcs(funcMap).ParseGlob("templates/layout.html"),
-	)
-	template.Must(base.ParseGlob("templates/partials/*.html"))
+	base, err := template.New("base").Funcs(funcMap).ParseFS(fsys, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := base.ParseFS(fsys, "partials/*.html"); err != nil {
+		return nil, err
+	}
 
 	// For each page template, clone the base and parse the page file on top.
 	pages := map[string]*template.Template{}
-	pageFiles, err := filepath.Glob("templates/*.html")
+	pageFiles, err := fs.Glob(fsys, "*.html")
 	if err != nil {
-		panic("failed to glob page templates: " + err.Error())
+		return nil, fmt.Errorf("failed to glob page templates: %w", err)
 	}
 
-	for _, f := range pageFiles {
-		name := filepath.Base(f)
+	for _, name := range pageFiles {
 		if name == "layout.html" {
 			continue
 		}
 		clone, err := base.Clone()
 		if err != nil {
-			panic("failed to clone base template: " + err.Error())
+			return nil, fmt.Errorf("failed to clone base template: %w", err)
+		}
+		if _, err := clone.ParseFS(fsys, name); err != nil {
+			return nil, err
 		}
-		template.Must(clone.ParseFiles(f))
 		pages[name] = clone
 	}
 
 	// Also support rendering partials directly (for htmx)
 	// Parse song-rows partial as its own template
-	partialFiles, _ := filepath.Glob("templates/partials/*.html")
+	partialFiles, _ := fs.Glob(fsys, "partials/*.html")
 	for _, f := range partialFiles {
-		name := "partials/" + filepath.Base(f)
-		t := template.Must(template.New("").Funcs(funcMap).ParseFiles(f))
-		pages[name] = t
+		t, err := template.New("").Funcs(funcMap).ParseFS(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		pages[f] = t
 	}
 
-	return &Templates{pages: pages}
+	return pages, nil
 }
 
 func capitalize(s string) string {